@@ -41,8 +41,13 @@ func main() {
 		&domain.File{},
 		&domain.User{},
 		&domain.Conversation{},
+		&domain.ConversationMember{},
 		&domain.Message{},
 		&domain.Reaction{},
+		&domain.ConversationEvent{},
+		&domain.Session{},
+		&domain.PasswordReset{},
+		&domain.EmailVerification{},
 	); err != nil {
 		log.Fatalf("Migration failed: %v", err)
 	}
@@ -0,0 +1,6 @@
+package dto
+
+type ServerNoticeRequest struct {
+	Message string `json:"message"`
+	Level   string `json:"level"`
+}
@@ -1,6 +1,8 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/yokeTH/chat-app-backend/internal/domain"
 )
 
@@ -12,6 +14,7 @@ type conversationDto struct {
 type ConversationDto interface {
 	ToResponse(conversation *domain.Conversation) (*ConversationResponse, error)
 	ToResponseList(conversations []domain.Conversation) (*[]ConversationResponse, error)
+	ToReadReceiptResponse(member *domain.ConversationMember) *ReadReceiptResponse
 }
 
 func NewConversationDto(userDto UserDto, messageDto MessageDto) *conversationDto {
@@ -31,12 +34,14 @@ func (c *conversationDto) ToResponse(conversation *domain.Conversation) (*Conver
 		lastMessage = (*messages)[len(*messages)-1]
 	}
 	return &ConversationResponse{
-		ID:          conversation.ID,
-		Name:        conversation.Name,
-		Members:     *c.userDto.ToResponseList(conversation.Members),
-		Messages:    *messages,
-		IsGroup:     conversation.IsGroup,
-		LastMessage: lastMessage,
+		ID:           conversation.ID,
+		Name:         conversation.Name,
+		Members:      *c.userDto.ToResponseList(conversation.Members),
+		Messages:     *messages,
+		IsGroup:      conversation.IsGroup,
+		LastMessage:  lastMessage,
+		UnreadCount:  conversation.UnreadCount,
+		ReadReceipts: c.toReadReceiptResponseList(conversation.Memberships),
 	}, nil
 }
 
@@ -53,16 +58,55 @@ func (c *conversationDto) ToResponseList(conversations []domain.Conversation) (*
 	return &response, nil
 }
 
+func (c *conversationDto) ToReadReceiptResponse(member *domain.ConversationMember) *ReadReceiptResponse {
+	resp := &ReadReceiptResponse{
+		ConversationID: member.ConversationID,
+		UserID:         member.UserID,
+	}
+	if member.LastReadMessageID != nil {
+		resp.MessageID = *member.LastReadMessageID
+	}
+	if member.LastReadAt != nil {
+		resp.ReadAt = *member.LastReadAt
+	}
+	return resp
+}
+
+// toReadReceiptResponseList skips members that have not read anything yet.
+func (c *conversationDto) toReadReceiptResponseList(members []domain.ConversationMember) []ReadReceiptResponse {
+	response := make([]ReadReceiptResponse, 0, len(members))
+	for _, member := range members {
+		if member.LastReadMessageID == nil {
+			continue
+		}
+		response = append(response, *c.ToReadReceiptResponse(&member))
+	}
+	return response
+}
+
 type ConversationResponse struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Members     []UserResponse    `json:"members"`
-	Messages    []MessageResponse `json:"messages"`
-	IsGroup     bool              `json:"isGroup"`
-	LastMessage MessageResponse   `json:"lastMessage"`
+	ID           string                `json:"id"`
+	Name         string                `json:"name"`
+	Members      []UserResponse        `json:"members"`
+	Messages     []MessageResponse     `json:"messages"`
+	IsGroup      bool                  `json:"isGroup"`
+	LastMessage  MessageResponse       `json:"lastMessage"`
+	UnreadCount  int                   `json:"unread_count"`
+	ReadReceipts []ReadReceiptResponse `json:"read_receipts"`
 }
 
 type CreateConversationRequest struct {
 	Name    string   `json:"name" validate:"required,min=2,max=100"`
 	Members []string `json:"members" validate:"required,min=2,dive,required"`
 }
+
+type ReadReceiptRequest struct {
+	MessageID string `json:"message_id" validate:"required,uuid4"`
+}
+
+type ReadReceiptResponse struct {
+	ConversationID string    `json:"conversation_id"`
+	UserID         string    `json:"user_id"`
+	MessageID      string    `json:"message_id"`
+	ReadAt         time.Time `json:"read_at"`
+}
@@ -20,6 +20,24 @@ type Pagination struct {
 	Total       int `json:"total"`
 }
 
+const (
+	DefaultPageLimit = 10
+	MaxPageLimit     = 50
+)
+
+// PageBounds applies the default and bounds of every paginated endpoint.
+func PageBounds(page, limit int) (int, int) {
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	} else if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+	return page, limit
+}
+
 func Success[T any](data T) SuccessResponse[T] {
 	return SuccessResponse[T]{Data: data}
 }
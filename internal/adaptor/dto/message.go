@@ -45,16 +45,24 @@ func (m *messageDto) ToResponse(e *domain.Message) (*MessageResponse, error) {
 		content = e.Content
 	}
 
+	var clientMessageID string
+	if e.ClientMessageID != nil {
+		clientMessageID = *e.ClientMessageID
+	}
+
 	return &MessageResponse{
-		ID:             e.ID,
-		Content:        content,
-		CreatedAt:      e.CreatedAt,
-		UpdatedAt:      e.UpdatedAt,
-		Sender:         *m.userDto.ToResponse(&e.Sender),
-		ConversationID: e.ConversationID,
-		Attachments:    *attachments,
-		Reactions:      *m.reactionDto.ToResponseList(e.Reactions),
-		MessageType:    string(e.MessageType),
+		ID:              e.ID,
+		Content:         content,
+		CreatedAt:       e.CreatedAt,
+		UpdatedAt:       e.UpdatedAt,
+		Sender:          *m.userDto.ToResponse(&e.Sender),
+		ConversationID:  e.ConversationID,
+		Seq:             e.Seq,
+		ClientMessageID: clientMessageID,
+		Attachments:     *attachments,
+		Reactions:       *m.reactionDto.ToResponseList(e.Reactions),
+		MessageType:     string(e.MessageType),
+		Deleted:         e.IsDeleted,
 	}, nil
 }
 
@@ -71,19 +79,25 @@ func (m *messageDto) ToResponseList(es []domain.Message) (*[]MessageResponse, er
 }
 
 type MessageResponse struct {
-	ID             string       `json:"id"`
-	Content        string       `json:"content"`
-	CreatedAt      time.Time    `json:"created_at"`
-	UpdatedAt      time.Time    `json:"updated_at"`
-	Sender         UserResponse `json:"sender"`
-	ConversationID string       `json:"conversation_id"`
-	MessageType    string       `json:"type"`
+	ID              string       `json:"id"`
+	Content         string       `json:"content"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	Sender          UserResponse `json:"sender"`
+	ConversationID  string       `json:"conversation_id"`
+	Seq             int64        `json:"seq"`
+	ClientMessageID string       `json:"client_message_id,omitempty"`
+	MessageType     string       `json:"type"`
+	// Deleted marks the tombstone of a deleted message sent on resume, its
+	// content, attachments and reactions are empty
+	Deleted bool `json:"deleted,omitempty"`
 	// Sender      UserResponse       `json:"senderId,omitempty"`
 	Attachments []FileResponse     `json:"attachments"`
 	Reactions   []ReactionResponse `json:"reactions"`
 }
 
 type CreateMessageRequest struct {
-	ConversationID string `json:"conversation_id" validate:"required,uuid4"`
-	Content        string `json:"content"`
+	ConversationID  string `json:"conversation_id" validate:"required,uuid4"`
+	Content         string `json:"content"`
+	ClientMessageID string `json:"client_message_id,omitempty" validate:"omitempty,max=64"`
 }
@@ -9,6 +9,7 @@ type reactionDto struct {
 type ReactionDto interface {
 	ToResponse(e *domain.Reaction) *ReactionResponse
 	ToResponseList(es []domain.Reaction) *[]ReactionResponse
+	ToMessageReactionsResponse(message *domain.Message) *MessageReactionsResponse
 }
 
 func NewReactionDto(userDto UserDto) *reactionDto {
@@ -32,7 +33,25 @@ func (r *reactionDto) ToResponseList(es []domain.Reaction) *[]ReactionResponse {
 	return &response
 }
 
+func (r *reactionDto) ToMessageReactionsResponse(message *domain.Message) *MessageReactionsResponse {
+	return &MessageReactionsResponse{
+		MessageID:      message.ID,
+		ConversationID: message.ConversationID,
+		Reactions:      *r.ToResponseList(message.Reactions),
+	}
+}
+
 type ReactionResponse struct {
 	Emoji string       `json:"emoji"`
 	User  UserResponse `json:"user"`
 }
+
+type MessageReactionsResponse struct {
+	MessageID      string             `json:"message_id"`
+	ConversationID string             `json:"conversation_id"`
+	Reactions      []ReactionResponse `json:"reactions"`
+}
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji" validate:"required,max=10"`
+}
@@ -9,6 +9,7 @@ import (
 type UserDto interface {
 	ToResponse(user *domain.User) *UserResponse
 	ToResponseList(users []domain.User) *[]UserResponse
+	ToPresenceResponseList(users []domain.User) *[]PresenceResponse
 }
 
 type userDto struct{}
@@ -19,13 +20,15 @@ func NewUserDto() *userDto {
 
 func (u *userDto) ToResponse(user *domain.User) *UserResponse {
 	return &UserResponse{
-		ID:        user.ID,
-		Name:      user.Name,
-		Email:     user.Email,
-		AvatarURL: user.AvatarURL,
-		IsOnline:  user.IsOnline,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+		ID:         user.ID,
+		Name:       user.Name,
+		Email:      user.Email,
+		AvatarURL:  user.AvatarURL,
+		IsOnline:   user.Status() != domain.PresenceOffline,
+		Status:     string(user.Status()),
+		LastSeenAt: user.LastSeen(),
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
 	}
 }
 
@@ -37,16 +40,36 @@ func (u *userDto) ToResponseList(users []domain.User) *[]UserResponse {
 	return &response
 }
 
+func (u *userDto) ToPresenceResponseList(users []domain.User) *[]PresenceResponse {
+	response := make([]PresenceResponse, len(users))
+	for i, user := range users {
+		response[i] = PresenceResponse{
+			UserID:     user.ID,
+			Status:     string(user.Status()),
+			LastSeenAt: user.LastSeen(),
+		}
+	}
+	return &response
+}
+
 type UpdateUserRequest struct {
 	Name string `json:"name" validate:"omitempty,min=2,max=100"`
 }
 
 type UserResponse struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	AvatarURL string    `json:"avatar"`
-	IsOnline  bool      `json:"is_online"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	AvatarURL  string     `json:"avatar"`
+	IsOnline   bool       `json:"is_online"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type PresenceResponse struct {
+	UserID     string     `json:"user_id"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
 }
@@ -0,0 +1,349 @@
+package google
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// clockSkew is how far the clocks of Google and this server may disagree.
+	clockSkew = time.Minute
+	// defaultKeysTTL applies when the JWKS response has no usable max-age.
+	defaultKeysTTL = time.Hour
+	// minKeysRefresh limits how often a token with an unknown key ID can
+	// make the verifier fetch the keys again.
+	minKeysRefresh = time.Minute
+)
+
+var issuers = map[string]struct{}{
+	"accounts.google.com":         {},
+	"https://accounts.google.com": {},
+}
+
+// idTokenVerifier verifies Google ID tokens locally, against the signing keys
+// published at config.JWKSURL.
+type idTokenVerifier struct {
+	clientID string
+	keys     *keySet
+}
+
+// NewIDTokenVerifier creates a verifier for ID tokens issued to config.ClientID.
+//
+// Usage Example:
+//
+//	verifier := google.NewIDTokenVerifier(google.Config{ClientID: clientID, JWKSURL: server.URL})
+//	profile, err := verifier.Verify(ctx, idToken)
+func NewIDTokenVerifier(config Config) *idTokenVerifier {
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &idTokenVerifier{
+		clientID: config.ClientID,
+		keys: &keySet{
+			url:    config.JWKSURL,
+			client: &http.Client{Timeout: config.Timeout},
+			keys:   make(map[string]*rsa.PublicKey),
+		},
+	}
+}
+
+type idTokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type idTokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Audience      audience `json:"aud"`
+	ExpiresAt     int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Sub           string   `json:"sub"`
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Name          string   `json:"name"`
+	GivenName     string   `json:"given_name"`
+	FamilyName    string   `json:"family_name"`
+	Picture       string   `json:"picture"`
+}
+
+func (v *idTokenVerifier) Verify(ctx context.Context, token string) (*domain.Profile, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JWT", ErrInvalidToken)
+	}
+
+	var header idTokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	// only RS256 is accepted, whatever the token claims, so a token cannot
+	// pick a weaker algorithm or none at all
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	key, err := v.keys.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case !claims.Audience.contains(v.clientID):
+		return nil, fmt.Errorf("%w: issued to another client", ErrInvalidToken)
+	case !hasIssuer(claims.Issuer):
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case claims.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)):
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	case claims.Sub == "" || claims.Email == "":
+		return nil, fmt.Errorf("%w: missing subject or email", ErrInvalidToken)
+	case !bool(claims.EmailVerified):
+		// accounts are matched by email, an unverified one could take over another user
+		return nil, fmt.Errorf("%w: email not verified", ErrInvalidToken)
+	}
+
+	return &domain.Profile{
+		Sub:           claims.Sub,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+func hasIssuer(issuer string) bool {
+	_, ok := issuers[issuer]
+	return ok
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	return nil
+}
+
+// audience is the aud claim, a single client ID or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flexBool accepts both true and "true", Google has used either for email_verified.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	value, err := strconv.ParseBool(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*b = flexBool(value)
+	return nil
+}
+
+// keySet caches the signing keys of a JWKS endpoint for as long as its
+// Cache-Control header allows. The endpoint is fetched without holding mu,
+// concurrent misses share one request.
+type keySet struct {
+	url    string
+	client *http.Client
+	group  singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	expiresAt   time.Time
+	lastFetched time.Time
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func (k *keySet) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	now := time.Now()
+
+	k.mu.Lock()
+	key, ok := k.keys[kid]
+	// an expired key is kept until the next fetch is allowed
+	usable := ok && (now.Before(k.expiresAt) || now.Sub(k.lastFetched) < minKeysRefresh)
+	k.mu.Unlock()
+
+	if usable {
+		return key, nil
+	}
+
+	// an unknown key waits for a fetch in flight, refresh itself keeps the
+	// keys from being fetched more than once a minute. The fetch outlives a
+	// caller that gives up, the others waiting on it still want the keys.
+	_, err, _ := k.group.Do("", func() (any, error) {
+		return nil, k.refresh(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		if ok {
+			// an expired key is better than failing every login while Google is unreachable
+			log.Printf("failed to refresh google signing keys, using the cached ones: %v", err)
+			return key, nil
+		}
+		return nil, err
+	}
+
+	k.mu.Lock()
+	key, ok = k.keys[kid]
+	k.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+	return key, nil
+}
+
+// refresh fetches the keys, it skips the fetch when another one started
+// within minKeysRefresh, whether for a key that is not published yet or
+// while the endpoint is failing.
+func (k *keySet) refresh(ctx context.Context) error {
+	now := time.Now()
+
+	k.mu.Lock()
+	if now.Sub(k.lastFetched) < minKeysRefresh {
+		k.mu.Unlock()
+		return nil
+	}
+	k.lastFetched = now
+	k.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("performing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var set jwks
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		key, err := rsaKey(jwk.N, jwk.E)
+		if err != nil {
+			log.Printf("skipping google signing key %s: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("no usable signing keys")
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.expiresAt = now.Add(maxAge(resp.Header.Get("Cache-Control")))
+	k.mu.Unlock()
+	return nil
+}
+
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, fmt.Errorf("decoding modulus: %w", err)
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, fmt.Errorf("decoding exponent: %w", err)
+	}
+	if len(exponent) == 0 || len(exponent) > 4 {
+		return nil, errors.New("unsupported exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(new(big.Int).SetBytes(exponent).Int64()),
+	}, nil
+}
+
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age=")
+		if !ok {
+			continue
+		}
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultKeysTTL
+}
@@ -0,0 +1,204 @@
+package google_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/google"
+)
+
+const testClientID = "client-id.apps.googleusercontent.com"
+
+func signIDToken(t *testing.T, key *rsa.PrivateKey, header, claims map[string]any) string {
+	t.Helper()
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		assert.Nil(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.Nil(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestIDTokenVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": "key-1",
+				"kty": "RSA",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer jwks.Close()
+
+	verifier := google.NewIDTokenVerifier(google.Config{ClientID: testClientID, JWKSURL: jwks.URL})
+
+	header := map[string]any{"alg": "RS256", "kid": "key-1", "typ": "JWT"}
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":            "https://accounts.google.com",
+			"aud":            testClientID,
+			"sub":            "google-1",
+			"email":          "user@example.com",
+			"email_verified": true,
+			"name":           "User",
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	tests := []struct {
+		description   string
+		token         string
+		expectedError bool
+	}{
+		{
+			description: "valid token",
+			token:       signIDToken(t, key, header, claims(nil)),
+		},
+		{
+			description: "audience list",
+			token:       signIDToken(t, key, header, claims(map[string]any{"aud": []string{"other", testClientID}})),
+		},
+		{
+			description: "email verified as a string",
+			token:       signIDToken(t, key, header, claims(map[string]any{"email_verified": "true"})),
+		},
+		{
+			description:   "unverified email",
+			token:         signIDToken(t, key, header, claims(map[string]any{"email_verified": false})),
+			expectedError: true,
+		},
+		{
+			description:   "unverified email as a string",
+			token:         signIDToken(t, key, header, claims(map[string]any{"email_verified": "false"})),
+			expectedError: true,
+		},
+		{
+			description:   "issued to another client",
+			token:         signIDToken(t, key, header, claims(map[string]any{"aud": "other"})),
+			expectedError: true,
+		},
+		{
+			description:   "unexpected issuer",
+			token:         signIDToken(t, key, header, claims(map[string]any{"iss": "https://example.com"})),
+			expectedError: true,
+		},
+		{
+			description:   "expired",
+			token:         signIDToken(t, key, header, claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+			expectedError: true,
+		},
+		{
+			description:   "signed with another key",
+			token:         signIDToken(t, otherKey, header, claims(nil)),
+			expectedError: true,
+		},
+		{
+			description:   "unknown key ID",
+			token:         signIDToken(t, key, map[string]any{"alg": "RS256", "kid": "key-2"}, claims(nil)),
+			expectedError: true,
+		},
+		{
+			description:   "unsigned",
+			token:         signIDToken(t, key, map[string]any{"alg": "none", "kid": "key-1"}, claims(nil)),
+			expectedError: true,
+		},
+		{
+			description:   "not a JWT",
+			token:         "ya29.access-token",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		profile, err := verifier.Verify(context.Background(), test.token)
+		if test.expectedError {
+			assert.ErrorIsf(t, err, google.ErrInvalidToken, test.description)
+			continue
+		}
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, "google-1", profile.Sub, test.description)
+		assert.Equalf(t, "user@example.com", profile.Email, test.description)
+		assert.Truef(t, profile.EmailVerified, test.description)
+	}
+}
+
+func TestIDTokenVerifierKeyFetch(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	var requests atomic.Int64
+	release := make(chan struct{})
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": "key-1",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer jwks.Close()
+
+	verifier := google.NewIDTokenVerifier(google.Config{ClientID: testClientID, JWKSURL: jwks.URL})
+	token := signIDToken(t, key, map[string]any{"alg": "RS256", "kid": "key-1"}, map[string]any{
+		"iss":            "accounts.google.com",
+		"aud":            testClientID,
+		"sub":            "google-1",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+
+	const logins = 8
+	errs := make(chan error, logins)
+	for range logins {
+		go func() {
+			_, err := verifier.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+
+	// every login waits on the first fetch instead of failing or fetching again
+	assert.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for range logins {
+		assert.Nil(t, <-errs)
+	}
+	assert.Equal(t, int64(1), requests.Load())
+}
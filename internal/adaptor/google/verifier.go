@@ -0,0 +1,229 @@
+package google
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrInvalidToken is returned for tokens Google rejected, as opposed to
+// failures to reach Google.
+var ErrInvalidToken = errors.New("invalid google token")
+
+// ErrMissingClientID is returned by New when neither kind of token could be
+// verified.
+var ErrMissingClientID = errors.New("google client ID is required unless access tokens are accepted")
+
+const maxCacheEntries = 10000
+
+type Config struct {
+	// ClientID is the OAuth client ID tokens have to be issued to, ID tokens
+	// are only accepted when it is set.
+	ClientID string `env:"CLIENT_ID"`
+	JWKSURL  string `env:"JWKS_URL" envDefault:"https://www.googleapis.com/oauth2/v3/certs"`
+	// AcceptAccessTokens keeps verifying access tokens with the userinfo
+	// endpoint, for clients that do not send ID tokens yet. It is deprecated
+	// and off unless enabled explicitly.
+	AcceptAccessTokens bool `env:"ACCEPT_ACCESS_TOKENS" envDefault:"false"`
+
+	UserInfoURL      string        `env:"USERINFO_URL" envDefault:"https://www.googleapis.com/oauth2/v3/userinfo"`
+	CacheTTL         time.Duration `env:"CACHE_TTL" envDefault:"5m"`
+	NegativeCacheTTL time.Duration `env:"NEGATIVE_CACHE_TTL" envDefault:"30s"`
+	Timeout          time.Duration `env:"TIMEOUT" envDefault:"10s"`
+}
+
+// TokenVerifier resolves a token presented by a client to the Google profile
+// it belongs to.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*domain.Profile, error)
+}
+
+// New creates the verifier selected by config. ID tokens are verified locally
+// when config.ClientID is set, any other token is an access token checked
+// with the userinfo endpoint if config.AcceptAccessTokens is on. New fails
+// with ErrMissingClientID when neither is set.
+//
+// Usage Example:
+//
+//	verifier, err := google.New(google.Config{ClientID: clientID, JWKSURL: "https://www.googleapis.com/oauth2/v3/certs"})
+func New(config Config) (TokenVerifier, error) {
+	if config.ClientID == "" && !config.AcceptAccessTokens {
+		return nil, ErrMissingClientID
+	}
+
+	var verifier tokenVerifier
+	if config.ClientID != "" {
+		verifier.idTokens = NewIDTokenVerifier(config)
+	}
+	if config.AcceptAccessTokens {
+		log.Printf("accepting google access tokens is deprecated, set GOOGLE_CLIENT_ID and send ID tokens instead")
+		verifier.accessTokens = NewUserInfoVerifier(config)
+	}
+	return &verifier, nil
+}
+
+// tokenVerifier routes a token to the verifier for its kind, ID tokens are
+// JWTs while Google's access tokens are opaque.
+type tokenVerifier struct {
+	idTokens     TokenVerifier
+	accessTokens TokenVerifier
+}
+
+func (v *tokenVerifier) Verify(ctx context.Context, token string) (*domain.Profile, error) {
+	if strings.Count(token, ".") == 2 && v.idTokens != nil {
+		return v.idTokens.Verify(ctx, token)
+	}
+	if v.accessTokens != nil {
+		return v.accessTokens.Verify(ctx, token)
+	}
+	return nil, fmt.Errorf("%w: only ID tokens are accepted", ErrInvalidToken)
+}
+
+type cacheEntry struct {
+	profile   *domain.Profile
+	err       error
+	expiresAt time.Time
+}
+
+// userInfoVerifier verifies access tokens with the userinfo endpoint. Results
+// are cached by token hash, rejected tokens for NegativeCacheTTL only, and
+// concurrent lookups of the same token share one request.
+type userInfoVerifier struct {
+	config Config
+	client *http.Client
+	group  singleflight.Group
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewUserInfoVerifier creates a verifier that calls config.UserInfoURL.
+//
+// Usage Example:
+//
+//	verifier := google.NewUserInfoVerifier(google.Config{UserInfoURL: server.URL, CacheTTL: time.Minute})
+//	profile, err := verifier.Verify(ctx, accessToken)
+func NewUserInfoVerifier(config Config) *userInfoVerifier {
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &userInfoVerifier{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		cache:  make(map[string]cacheEntry),
+	}
+}
+
+func (v *userInfoVerifier) Verify(ctx context.Context, token string) (*domain.Profile, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: empty token", ErrInvalidToken)
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(sum[:])
+
+	if entry, ok := v.cached(key); ok {
+		return entry.profile, entry.err
+	}
+
+	result, err, _ := v.group.Do(key, func() (any, error) {
+		profile, err := v.fetch(ctx, token)
+		switch {
+		case err == nil:
+			v.store(key, cacheEntry{profile: profile}, v.config.CacheTTL)
+		case errors.Is(err, ErrInvalidToken):
+			v.store(key, cacheEntry{err: err}, v.config.NegativeCacheTTL)
+		}
+		return profile, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*domain.Profile), nil
+}
+
+func (v *userInfoVerifier) fetch(ctx context.Context, token string) (*domain.Profile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.config.UserInfoURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("performing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var profile domain.Profile
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	if profile.Sub == "" || profile.Email == "" {
+		return nil, fmt.Errorf("%w: missing subject or email in profile", ErrInvalidToken)
+	}
+	if !profile.EmailVerified {
+		return nil, fmt.Errorf("%w: email not verified", ErrInvalidToken)
+	}
+
+	return &profile, nil
+}
+
+func (v *userInfoVerifier) cached(key string) (cacheEntry, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry, ok := v.cache[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(v.cache, key)
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (v *userInfoVerifier) store(key string, entry cacheEntry, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	if len(v.cache) >= maxCacheEntries {
+		for cachedKey, cached := range v.cache {
+			if now.After(cached.expiresAt) {
+				delete(v.cache, cachedKey)
+			}
+		}
+	}
+	// a full cache of live entries only costs extra requests, not memory
+	if len(v.cache) >= maxCacheEntries {
+		return
+	}
+
+	entry.expiresAt = now.Add(ttl)
+	v.cache[key] = entry
+}
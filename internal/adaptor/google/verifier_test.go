@@ -0,0 +1,138 @@
+package google_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/google"
+)
+
+func TestUserInfoVerifier(t *testing.T) {
+	var requests atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.Header.Get("Authorization") {
+		case "Bearer valid":
+			_, _ = w.Write([]byte(`{"sub":"google-1","email":"user@example.com","email_verified":true,"name":"User"}`))
+		case "Bearer unverified":
+			_, _ = w.Write([]byte(`{"sub":"google-3","email":"user@example.com","email_verified":false}`))
+		case "Bearer no-email":
+			_, _ = w.Write([]byte(`{"sub":"google-2"}`))
+		case "Bearer unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer upstream.Close()
+
+	verifier := google.NewUserInfoVerifier(google.Config{
+		UserInfoURL:      upstream.URL,
+		CacheTTL:         time.Minute,
+		NegativeCacheTTL: time.Minute,
+	})
+
+	tests := []struct {
+		description      string
+		token            string
+		expectedError    error
+		expectedFailure  bool
+		expectedRequests int64
+	}{
+		{
+			description:      "valid token",
+			token:            "valid",
+			expectedRequests: 1,
+		},
+		{
+			description:      "valid token is cached",
+			token:            "valid",
+			expectedRequests: 1,
+		},
+		{
+			description:      "rejected token",
+			token:            "expired",
+			expectedError:    google.ErrInvalidToken,
+			expectedRequests: 2,
+		},
+		{
+			description:      "rejected token is cached",
+			token:            "expired",
+			expectedError:    google.ErrInvalidToken,
+			expectedRequests: 2,
+		},
+		{
+			description:      "profile without email",
+			token:            "no-email",
+			expectedError:    google.ErrInvalidToken,
+			expectedRequests: 3,
+		},
+		{
+			description:      "unverified email",
+			token:            "unverified",
+			expectedError:    google.ErrInvalidToken,
+			expectedRequests: 4,
+		},
+		{
+			description:      "upstream failure",
+			token:            "unavailable",
+			expectedFailure:  true,
+			expectedRequests: 5,
+		},
+		{
+			description:      "upstream failure is not cached",
+			token:            "unavailable",
+			expectedFailure:  true,
+			expectedRequests: 6,
+		},
+	}
+
+	for _, test := range tests {
+		profile, err := verifier.Verify(context.Background(), test.token)
+		switch {
+		case test.expectedError != nil:
+			assert.ErrorIsf(t, err, test.expectedError, test.description)
+		case test.expectedFailure:
+			assert.NotNilf(t, err, test.description)
+			assert.NotErrorIsf(t, err, google.ErrInvalidToken, test.description)
+		default:
+			assert.Nilf(t, err, test.description)
+			assert.Equalf(t, "google-1", profile.Sub, test.description)
+			assert.Equalf(t, "user@example.com", profile.Email, test.description)
+		}
+		assert.Equalf(t, test.expectedRequests, requests.Load(), test.description)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		description   string
+		config        google.Config
+		expectedError error
+	}{
+		{
+			description:   "no client ID",
+			config:        google.Config{},
+			expectedError: google.ErrMissingClientID,
+		},
+		{
+			description: "client ID",
+			config:      google.Config{ClientID: "client-1"},
+		},
+		{
+			description: "access tokens only",
+			config:      google.Config{AcceptAccessTokens: true},
+		},
+	}
+
+	for _, test := range tests {
+		verifier, err := google.New(test.config)
+		assert.Equalf(t, test.expectedError, err, test.description)
+		assert.Equalf(t, test.expectedError == nil, verifier != nil, test.description)
+	}
+}
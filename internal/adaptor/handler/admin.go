@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+type adminHandler struct {
+	mServer websocket.MessageServer
+}
+
+func NewAdminHandler(mServer websocket.MessageServer) *adminHandler {
+	return &adminHandler{
+		mServer: mServer,
+	}
+}
+
+// ListConnections godoc
+//
+//	@summary		List connections
+//	@description	list the live sockets and event streams held by the replica serving the request only,
+//	@description	connections on other replicas are not listed but Disconnect and DisconnectUser still reach them
+//	@tags			admin
+//	@Security		Bearer
+//	@produce		json
+//	@Param			user_id	query	string	false	"Only list the connections of this user"
+//	@response		200	{object}	dto.SuccessResponse[[]websocket.ConnectionInfo]	"OK"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
+//	@Router /admin/connections [get]
+func (h *adminHandler) HandleListConnections(c *fiber.Ctx) error {
+	return c.JSON(dto.Success(h.mServer.Connections(c.Query("user_id"))))
+}
+
+// ConnectionStats godoc
+//
+//	@summary		Connection stats
+//	@description	report the outbound queue and event counters of the replica serving the request
+//	@tags			admin
+//	@Security		Bearer
+//	@produce		json
+//	@response		200	{object}	dto.SuccessResponse[websocket.ConnectionStats]	"OK"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
+//	@Router /admin/connections/stats [get]
+func (h *adminHandler) HandleConnectionStats(c *fiber.Ctx) error {
+	return c.JSON(dto.Success(websocket.ConnectionStats{
+		Queue:  h.mServer.QueueStats(),
+		Events: h.mServer.EventStats(),
+	}))
+}
+
+// DisconnectConnection godoc
+//
+//	@summary		Disconnect connection
+//	@description	force-disconnect a single connection on whichever replica holds it
+//	@tags			admin
+//	@Security		Bearer
+//	@Param			id	path	string	true	"Connection ID"
+//	@response		204	"No Content"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /admin/connections/{id} [delete]
+func (h *adminHandler) HandleDisconnectConnection(c *fiber.Ctx) error {
+	if err := h.mServer.Disconnect(c.Params("id")); err != nil {
+		return apperror.InternalServerError(err, "failed to disconnect connection")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// DisconnectUser godoc
+//
+//	@summary		Disconnect user
+//	@description	force-disconnect every connection of a user on every replica
+//	@tags			admin
+//	@Security		Bearer
+//	@Param			id	path	string	true	"User ID"
+//	@response		204	"No Content"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /admin/users/{id}/connections [delete]
+func (h *adminHandler) HandleDisconnectUser(c *fiber.Ctx) error {
+	if err := h.mServer.DisconnectUser(c.Params("id")); err != nil {
+		return apperror.InternalServerError(err, "failed to disconnect user")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SendNotice godoc
+//
+//	@summary		Send notice
+//	@description	push a server notice to every connection of a user on every replica
+//	@tags			admin
+//	@Security		Bearer
+//	@accept			json
+//	@Param			id		path	string					true	"User ID"
+//	@param			notice	body	dto.ServerNoticeRequest	true	"Notice"
+//	@response		202	"Accepted"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /admin/users/{id}/notices [post]
+func (h *adminHandler) HandleSendNotice(c *fiber.Ctx) error {
+	body := new(dto.ServerNoticeRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+	if body.Message == "" {
+		return apperror.BadRequestError(errors.New("empty notice"), "message is required")
+	}
+
+	if err := h.mServer.SendNotice(c.Params("id"), websocket.ServerNotice{
+		Message: body.Message,
+		Level:   body.Level,
+	}); err != nil {
+		return apperror.InternalServerError(err, "failed to send notice")
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
@@ -0,0 +1,133 @@
+package handler_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/handler"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+type fakeMessageServer struct {
+	websocket.MessageServer
+	listed       []string
+	disconnected []string
+	notices      map[string]websocket.ServerNotice
+	err          error
+}
+
+func (s *fakeMessageServer) Connections(userID string) []websocket.ConnectionInfo {
+	s.listed = append(s.listed, userID)
+	return []websocket.ConnectionInfo{{ID: "conn", UserID: "user", Transport: websocket.TransportWebSocket}}
+}
+
+func (s *fakeMessageServer) Disconnect(connectionID string) error {
+	s.disconnected = append(s.disconnected, "connection:"+connectionID)
+	return s.err
+}
+
+func (s *fakeMessageServer) DisconnectUser(userID string) error {
+	s.disconnected = append(s.disconnected, "user:"+userID)
+	return s.err
+}
+
+func (s *fakeMessageServer) SendNotice(userID string, notice websocket.ServerNotice) error {
+	s.notices[userID] = notice
+	return s.err
+}
+
+func newAdminApp(mServer websocket.MessageServer) *fiber.App {
+	h := handler.NewAdminHandler(mServer)
+	app := fiber.New(fiber.Config{ErrorHandler: apperror.ErrorHandler})
+	app.Get("/admin/connections", h.HandleListConnections)
+	app.Delete("/admin/connections/:id", h.HandleDisconnectConnection)
+	app.Delete("/admin/users/:id/connections", h.HandleDisconnectUser)
+	app.Post("/admin/users/:id/notices", h.HandleSendNotice)
+	return app
+}
+
+func TestAdminHandler(t *testing.T) {
+	tests := []struct {
+		description          string
+		method               string
+		route                string
+		body                 string
+		err                  error
+		expectedCode         int
+		expectedDisconnected []string
+		expectedNotice       string
+	}{
+		{
+			description:          "disconnect connection",
+			method:               "DELETE",
+			route:                "/admin/connections/conn",
+			expectedCode:         fiber.StatusNoContent,
+			expectedDisconnected: []string{"connection:conn"},
+		},
+		{
+			description:          "disconnect user",
+			method:               "DELETE",
+			route:                "/admin/users/user/connections",
+			expectedCode:         fiber.StatusNoContent,
+			expectedDisconnected: []string{"user:user"},
+		},
+		{
+			description:          "disconnect failure",
+			method:               "DELETE",
+			route:                "/admin/users/user/connections",
+			err:                  errors.New("backplane down"),
+			expectedCode:         fiber.StatusInternalServerError,
+			expectedDisconnected: []string{"user:user"},
+		},
+		{
+			description:    "send notice",
+			method:         "POST",
+			route:          "/admin/users/user/notices",
+			body:           `{"message":"maintenance at noon","level":"warning"}`,
+			expectedCode:   fiber.StatusAccepted,
+			expectedNotice: "maintenance at noon",
+		},
+		{
+			description:  "empty notice",
+			method:       "POST",
+			route:        "/admin/users/user/notices",
+			body:         `{"message":""}`,
+			expectedCode: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		mServer := &fakeMessageServer{notices: map[string]websocket.ServerNotice{}, err: test.err}
+		app := newAdminApp(mServer)
+
+		req, _ := http.NewRequest(test.method, test.route, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, test.expectedCode, res.StatusCode, test.description)
+		assert.Equalf(t, test.expectedDisconnected, mServer.disconnected, test.description)
+		assert.Equalf(t, test.expectedNotice, mServer.notices["user"].Message, test.description)
+	}
+}
+
+func TestAdminListConnections(t *testing.T) {
+	mServer := &fakeMessageServer{}
+	app := newAdminApp(mServer)
+
+	req, _ := http.NewRequest("GET", "/admin/connections?user_id=user", nil)
+	res, err := app.Test(req, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, fiber.StatusOK, res.StatusCode)
+	assert.Equal(t, []string{"user"}, mServer.listed)
+
+	body, err := io.ReadAll(res.Body)
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), `"id":"conn"`)
+	assert.Contains(t, string(body), `"transport":"websocket"`)
+}
@@ -2,23 +2,52 @@ package handler
 
 import (
 	"errors"
+	"log"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
 	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/account"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/session"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/user"
 	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+	"github.com/yokeTH/chat-app-backend/pkg/ticket"
 )
 
 type authHandler struct {
-	userUseCase user.UserUseCase
+	userUseCase    user.UserUseCase
+	accountUseCase account.AccountUseCase
+	sessionUseCase session.SessionUseCase
+	ticketer       ticket.Ticketer
+	streamTicketer ticket.Ticketer
+	userDto        dto.UserDto
+	mServer        websocket.MessageServer
 }
 
-func NewAuthHandler(userUC user.UserUseCase) *authHandler {
+func NewAuthHandler(userUC user.UserUseCase, accountUC account.AccountUseCase, sessionUC session.SessionUseCase, ticketer, streamTicketer ticket.Ticketer, userDto dto.UserDto, mServer websocket.MessageServer) *authHandler {
 	return &authHandler{
-		userUseCase: userUC,
+		userUseCase:    userUC,
+		accountUseCase: accountUC,
+		sessionUseCase: sessionUC,
+		ticketer:       ticketer,
+		streamTicketer: streamTicketer,
+		userDto:        userDto,
+		mServer:        mServer,
 	}
 }
 
+// HandleGoogleLogin godoc
+//
+//	@summary		GoogleLogin
+//	@description	sign in with a Google token and start a session, later requests use the returned access token
+//	@tags			auth
+//	@Security		Bearer
+//	@produce		json
+//	@response		200	{object}	dto.SuccessResponse[dto.LoginResponse]	"OK"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/google [post]
 func (a *authHandler) HandleGoogleLogin(c *fiber.Ctx) error {
 	profile, ok := c.Locals("profile").(domain.Profile)
 	if !ok {
@@ -28,5 +57,338 @@ func (a *authHandler) HandleGoogleLogin(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
-	return c.JSON(user)
+
+	return a.startSession(c, fiber.StatusOK, user)
+}
+
+// HandleRegister godoc
+//
+//	@summary		Register
+//	@description	create an account with an email and a password and mail a link to verify the email, the account signs in once it is verified.
+//	@description	When the email belongs to a Google account, the password is added to it once the link is followed.
+//	@tags			auth
+//	@accept			json
+//	@param			body	body	dto.RegisterRequest	true	"Account"
+//	@response		202	"Accepted"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		409	{object}	dto.ErrorResponse	"Conflict"
+//	@response		429	{object}	dto.ErrorResponse	"Too Many Requests"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/register [post]
+func (a *authHandler) HandleRegister(c *fiber.Ctx) error {
+	body := new(dto.RegisterRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+
+	if err := a.accountUseCase.Register(c.Context(), body.Name, body.Email, body.Password); err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// HandleVerifyEmail godoc
+//
+//	@summary		VerifyEmail
+//	@description	verify an email with the token of a verification link and start a session
+//	@tags			auth
+//	@accept			json
+//	@produce		json
+//	@param			body	body	dto.VerifyEmailRequest	true	"Verification"
+//	@response		200	{object}	dto.SuccessResponse[dto.LoginResponse]	"OK"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/verify-email [post]
+func (a *authHandler) HandleVerifyEmail(c *fiber.Ctx) error {
+	body := new(dto.VerifyEmailRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+	if body.Token == "" {
+		return apperror.BadRequestError(errors.New("empty verification token"), "token is required")
+	}
+
+	user, err := a.accountUseCase.VerifyEmail(body.Token)
+	if err != nil {
+		return err
+	}
+	return a.startSession(c, fiber.StatusOK, user)
+}
+
+// HandleResendVerification godoc
+//
+//	@summary		ResendVerification
+//	@description	mail a new email verification link, the response is the same whether or not the email has an unverified account
+//	@tags			auth
+//	@accept			json
+//	@param			body	body	dto.ResendVerificationRequest	true	"Email"
+//	@response		202	"Accepted"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		429	{object}	dto.ErrorResponse	"Too Many Requests"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/verify-email/resend [post]
+func (a *authHandler) HandleResendVerification(c *fiber.Ctx) error {
+	body := new(dto.ResendVerificationRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+	if body.Email == "" {
+		return apperror.BadRequestError(errors.New("empty email"), "email is required")
+	}
+
+	if err := a.accountUseCase.ResendVerification(c.Context(), body.Email); err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// HandleLogin godoc
+//
+//	@summary		Login
+//	@description	sign in with an email and a password and start a session, the email has to be verified first
+//	@tags			auth
+//	@accept			json
+//	@produce		json
+//	@param			body	body	dto.LoginRequest	true	"Credentials"
+//	@response		200	{object}	dto.SuccessResponse[dto.LoginResponse]	"OK"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
+//	@response		429	{object}	dto.ErrorResponse	"Too Many Requests"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/login [post]
+func (a *authHandler) HandleLogin(c *fiber.Ctx) error {
+	body := new(dto.LoginRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+
+	user, err := a.accountUseCase.Login(body.Email, body.Password)
+	if err != nil {
+		return err
+	}
+
+	return a.startSession(c, fiber.StatusOK, user)
+}
+
+// HandleChangePassword godoc
+//
+//	@summary		ChangePassword
+//	@description	change the password of the current user, every session is ended along with its access tokens and sockets, and a new one is returned
+//	@tags			auth
+//	@Security		Bearer
+//	@accept			json
+//	@produce		json
+//	@param			body	body	dto.ChangePasswordRequest	true	"Passwords"
+//	@response		200	{object}	dto.SuccessResponse[dto.TokenResponse]	"OK"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/password [put]
+func (a *authHandler) HandleChangePassword(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("get user error"), "get user error")
+	}
+
+	body := new(dto.ChangePasswordRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+
+	if err := a.accountUseCase.ChangePassword(user.ID, body.CurrentPassword, body.NewPassword); err != nil {
+		return err
+	}
+	if err := a.sessionUseCase.LogoutAll(user.ID); err != nil {
+		return err
+	}
+	a.disconnect(user.ID)
+
+	tokens, err := a.sessionUseCase.Start(user.ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(dto.Success(dto.ToTokenResponse(tokens)))
+}
+
+// HandleForgotPassword godoc
+//
+//	@summary		ForgotPassword
+//	@description	mail a password reset link, the response is the same whether or not the email has an account
+//	@tags			auth
+//	@accept			json
+//	@param			body	body	dto.ForgotPasswordRequest	true	"Email"
+//	@response		202	"Accepted"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		429	{object}	dto.ErrorResponse	"Too Many Requests"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/password/forgot [post]
+func (a *authHandler) HandleForgotPassword(c *fiber.Ctx) error {
+	body := new(dto.ForgotPasswordRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+	if body.Email == "" {
+		return apperror.BadRequestError(errors.New("empty email"), "email is required")
+	}
+
+	if err := a.accountUseCase.RequestPasswordReset(c.Context(), body.Email); err != nil {
+		return err
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// HandleResetPassword godoc
+//
+//	@summary		ResetPassword
+//	@description	choose a new password with the token of a reset link, every session of the user is ended along with its access tokens and sockets
+//	@tags			auth
+//	@accept			json
+//	@param			body	body	dto.ResetPasswordRequest	true	"Reset"
+//	@response		204	"No Content"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/password/reset [post]
+func (a *authHandler) HandleResetPassword(c *fiber.Ctx) error {
+	body := new(dto.ResetPasswordRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+	if body.Token == "" {
+		return apperror.BadRequestError(errors.New("empty reset token"), "token is required")
+	}
+
+	user, err := a.accountUseCase.ResetPassword(body.Token, body.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := a.sessionUseCase.LogoutAll(user.ID); err != nil {
+		return err
+	}
+	a.disconnect(user.ID)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (a *authHandler) startSession(c *fiber.Ctx, status int, user *domain.User) error {
+	tokens, err := a.sessionUseCase.Start(user.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(status).JSON(dto.Success(dto.LoginResponse{
+		User:          *a.userDto.ToResponse(user),
+		TokenResponse: dto.ToTokenResponse(tokens),
+	}))
+}
+
+// HandleRefresh godoc
+//
+//	@summary		Refresh
+//	@description	trade a refresh token for a new access token and refresh token, the old refresh token stops working
+//	@tags			auth
+//	@accept			json
+//	@produce		json
+//	@param			body	body	dto.RefreshRequest	true	"Refresh token"
+//	@response		200	{object}	dto.SuccessResponse[dto.TokenResponse]	"OK"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/refresh [post]
+func (a *authHandler) HandleRefresh(c *fiber.Ctx) error {
+	body := new(dto.RefreshRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+	if body.RefreshToken == "" {
+		return apperror.BadRequestError(errors.New("empty refresh token"), "refresh_token is required")
+	}
+
+	tokens, err := a.sessionUseCase.Refresh(body.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.Success(dto.ToTokenResponse(tokens)))
+}
+
+// HandleLogout godoc
+//
+//	@summary		Logout
+//	@description	end the session of a refresh token, revoking its access tokens and closing the sockets and event streams opened in it
+//	@tags			auth
+//	@accept			json
+//	@param			body	body	dto.RefreshRequest	true	"Refresh token"
+//	@response		204	"No Content"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/logout [post]
+func (a *authHandler) HandleLogout(c *fiber.Ctx) error {
+	body := new(dto.RefreshRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+	if body.RefreshToken == "" {
+		return apperror.BadRequestError(errors.New("empty refresh token"), "refresh_token is required")
+	}
+
+	sessionID, err := a.sessionUseCase.Logout(body.RefreshToken)
+	if err != nil {
+		return err
+	}
+	if sessionID != "" {
+		// only the connections opened in this session, the user's other
+		// devices stay logged in
+		if err := a.mServer.DisconnectSession(sessionID); err != nil {
+			log.Printf("failed to disconnect session %s: %v", sessionID, err)
+		}
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// disconnect closes the sockets and event streams of a user whose session
+// ended, the sessions are already revoked so a failure is only logged.
+func (a *authHandler) disconnect(userID string) {
+	if err := a.mServer.DisconnectUser(userID); err != nil {
+		log.Printf("failed to disconnect user %s: %v", userID, err)
+	}
+}
+
+// HandleIssueWebsocketTicket godoc
+//
+//	@summary		IssueWebsocketTicket
+//	@description	issue a single-use ticket to open the websocket with, pass it as the ticket query parameter or in the first frame. With transport sse the ticket opens GET /events instead
+//	@tags			auth
+//	@Security		Bearer
+//	@produce		json
+//	@Param			transport	query	string	false	"websocket (default) or sse"
+//	@response		200	{object}	dto.SuccessResponse[dto.WebsocketTicketResponse]	"OK"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /auth/ws-ticket [post]
+func (a *authHandler) HandleIssueWebsocketTicket(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("get user error"), "get user error")
+	}
+
+	ticketer := a.ticketer
+	switch c.Query("transport") {
+	case "", websocket.TransportWebSocket:
+	case websocket.TransportSSE:
+		ticketer = a.streamTicketer
+	default:
+		return apperror.BadRequestError(errors.New("unknown ticket transport"), "transport must be websocket or sse")
+	}
+
+	// the session is recorded on the connection, so logging out of it closes the connection
+	sessionID, _ := c.Locals("session").(string)
+	token, expiresAt, err := ticketer.IssueForSession(user.ID, sessionID, 0)
+	if err != nil {
+		return apperror.InternalServerError(err, "failed to issue websocket ticket")
+	}
+
+	return c.JSON(dto.Success(dto.WebsocketTicketResponse{
+		Ticket:    token,
+		ExpiresAt: expiresAt,
+	}))
 }
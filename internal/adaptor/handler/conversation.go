@@ -2,32 +2,26 @@ package handler
 
 import (
 	"errors"
-	"fmt"
 	"log"
-	"time"
 
-	"github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
 	"github.com/yokeTH/chat-app-backend/internal/domain"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
-	"github.com/yokeTH/chat-app-backend/internal/usecase/message"
 	"github.com/yokeTH/chat-app-backend/pkg/apperror"
 )
 
 type conversationHandler struct {
 	convUC     conversation.ConversationUseCase
-	msgUC      message.MessageUseCase
 	dto        dto.ConversationDto
 	mServer    websocket.MessageServer
 	messageDto dto.MessageDto
 }
 
-func NewConversationHandler(convUC conversation.ConversationUseCase, dto dto.ConversationDto, mServer websocket.MessageServer, msgUC message.MessageUseCase, messageDto dto.MessageDto) *conversationHandler {
+func NewConversationHandler(convUC conversation.ConversationUseCase, dto dto.ConversationDto, mServer websocket.MessageServer, messageDto dto.MessageDto) *conversationHandler {
 	return &conversationHandler{
 		convUC:     convUC,
-		msgUC:      msgUC,
 		dto:        dto,
 		mServer:    mServer,
 		messageDto: messageDto,
@@ -92,7 +86,7 @@ func (c *conversationHandler) HandleCreateConversation(ctx *fiber.Ctx) error {
 	if !ok {
 		return apperror.InternalServerError(errors.New("get profile error"), "get profile error")
 	}
-	conversation, err := c.convUC.CreateConversation(body.Members, user.ID, body.Name)
+	conversation, system, err := c.convUC.CreateConversation(body.Members, user, body.Name)
 	if err != nil {
 		return err
 	}
@@ -103,33 +97,13 @@ func (c *conversationHandler) HandleCreateConversation(ctx *fiber.Ctx) error {
 	}
 	resp := dto.Success(respData)
 
-	system, err := c.msgUC.CreateSystemMessage(conversation.ID, fmt.Sprintf("Chat has been created by %s", user.Name))
-	if err != nil {
-		return err
-	}
-
 	createdMessageResponse, err := c.messageDto.ToResponse(system)
 	if err != nil {
 		log.Printf("failed to transform to dto: %v", err)
 		return err
 	}
 
-	payloadResponse, err := json.Marshal(createdMessageResponse)
-	if err != nil {
-		log.Printf("failed to encode json: %v", err)
-		return err
-	}
-
-	createdMessageJson, err := json.Marshal(websocket.WebSocketMessage{
-		Event:     websocket.EventTypeMessage,
-		Payload:   payloadResponse,
-		CreatedAt: time.Now().UnixMilli(),
-	})
-	if err != nil {
-		return apperror.InternalServerError(err, err.Error())
-	}
-
-	if err := c.mServer.BroadcastToMembersInConversation(conversation.ID, createdMessageJson); err != nil {
+	if err := c.mServer.BroadcastMessage(*createdMessageResponse); err != nil {
 		return apperror.InternalServerError(err, "broadcast error")
 	}
 
@@ -148,11 +122,17 @@ func (c *conversationHandler) HandleCreateConversation(ctx *fiber.Ctx) error {
 //	@response		400	{object}	dto.ErrorResponse	"Bad Request"
 //	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
 //	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
 //	@Router /conversations/{id} [get]
 func (c *conversationHandler) HandleGetConversation(ctx *fiber.Ctx) error {
 	id := ctx.Params("id")
 
-	conversation, err := c.convUC.GetConversation(id)
+	user, ok := ctx.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
+	}
+
+	conversation, err := c.convUC.GetUserConversation(id, user.ID)
 	if err != nil {
 		return err
 	}
@@ -178,6 +158,8 @@ func (c *conversationHandler) HandleGetConversation(ctx *fiber.Ctx) error {
 //	@response		400	{object}	dto.ErrorResponse	"Bad Request"
 //	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
 //	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
+//	@response		409	{object}	dto.ErrorResponse	"Conflict"
 //	@Router /conversations/{id}/join [post]
 func (c *conversationHandler) HandleJoinConversation(ctx *fiber.Ctx) error {
 	id := ctx.Params("id")
@@ -187,11 +169,7 @@ func (c *conversationHandler) HandleJoinConversation(ctx *fiber.Ctx) error {
 		return apperror.InternalServerError(errors.New("get profile error"), "get profile error")
 	}
 
-	if err := c.convUC.AddMember(id, user.ID); err != nil {
-		return err
-	}
-
-	conversation, err := c.convUC.GetConversation(id)
+	conversation, system, err := c.convUC.JoinConversation(id, user)
 	if err != nil {
 		return err
 	}
@@ -203,35 +181,58 @@ func (c *conversationHandler) HandleJoinConversation(ctx *fiber.Ctx) error {
 
 	c.mServer.BoardcastConversation(*respData)
 
-	system, err := c.msgUC.CreateSystemMessage(conversation.ID, fmt.Sprintf("%s has entered the chat", user.Name))
-	if err != nil {
-		return err
-	}
-
 	createdMessageResponse, err := c.messageDto.ToResponse(system)
 	if err != nil {
 		log.Printf("failed to transform to dto: %v", err)
 		return err
 	}
 
-	payloadResponse, err := json.Marshal(createdMessageResponse)
-	if err != nil {
-		log.Printf("failed to encode json: %v", err)
-		return err
+	if err := c.mServer.BroadcastMessage(*createdMessageResponse); err != nil {
+		return apperror.InternalServerError(err, "broadcast error")
 	}
 
-	createdMessageJson, err := json.Marshal(websocket.WebSocketMessage{
-		Event:     websocket.EventTypeMessage,
-		Payload:   payloadResponse,
-		CreatedAt: time.Now().UnixMilli(),
-	})
+	return ctx.JSON(resp)
+}
+
+// ReadConversation godoc
+//
+//	@summary		Mark conversation as read
+//	@description	Move the caller's read pointer to the given message and notify other members
+//	@tags			conversation
+//	@Security		Bearer
+//	@accept			json
+//	@produce		json
+//	@Param			id		path	string					true	"conversation id"
+//	@param			receipt	body	dto.ReadReceiptRequest	true	"last read message"
+//	@response		200	{object}	dto.SuccessResponse[dto.ReadReceiptResponse]	"OK"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
+//	@response		404	{object}	dto.ErrorResponse	"Not Found"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /conversations/{id}/read [post]
+func (c *conversationHandler) HandleReadConversation(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+
+	body := new(dto.ReadReceiptRequest)
+	if err := ctx.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, "invalid body")
+	}
+
+	user, ok := ctx.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
+	}
+
+	member, err := c.convUC.MarkAsRead(id, user.ID, body.MessageID)
 	if err != nil {
-		return apperror.InternalServerError(err, err.Error())
+		return err
 	}
 
-	if err := c.mServer.BroadcastToMembersInConversation(conversation.ID, createdMessageJson); err != nil {
+	respData := c.dto.ToReadReceiptResponse(member)
+	if err := c.mServer.BroadcastReadReceipt(*respData); err != nil {
 		return apperror.InternalServerError(err, "broadcast error")
 	}
 
-	return ctx.JSON(resp)
+	return ctx.JSON(dto.Success(respData))
 }
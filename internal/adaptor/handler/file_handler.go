@@ -3,9 +3,7 @@ package handler
 import (
 	"errors"
 	"log"
-	"time"
 
-	"github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
@@ -44,6 +42,7 @@ func NewFileHandler(uc file.FileUseCase, dto dto.FileDto, msgUC message.MessageU
 //	@param			id		path		string 	true "file data"
 //	@success 		201	{object}	dto.SuccessResponse[dto.FileResponse]	"Created"
 //	@failure		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@failure		403	{object}	dto.ErrorResponse	"Forbidden"
 //	@failure 		500	{object}	dto.ErrorResponse	"Internal Server Error"
 //	@Router /conversations/{id}/files [post]
 func (h *fileHandler) CreateFile(c *fiber.Ctx) error {
@@ -91,23 +90,7 @@ func (h *fileHandler) CreateFile(c *fiber.Ctx) error {
 		return err
 	}
 
-	payloadResponse, err := json.Marshal(createdMessageResponse)
-	if err != nil {
-		log.Printf("failed to encode json: %v", err)
-		return err
-	}
-
-	createdMessageJson, err := json.Marshal(websocket.WebSocketMessage{
-		Event:     websocket.EventTypeMessage,
-		Payload:   payloadResponse,
-		CreatedAt: time.Now().UnixMilli(),
-	})
-	if err != nil {
-		log.Printf("failed to encode json: %v", err)
-		return err
-	}
-
-	if err := h.mServer.BroadcastToMembersInConversation(conversationID, createdMessageJson); err != nil {
+	if err := h.mServer.BroadcastMessage(*createdMessageResponse); err != nil {
 		return err
 	}
 
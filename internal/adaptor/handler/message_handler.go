@@ -5,6 +5,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
 	"github.com/yokeTH/chat-app-backend/internal/domain"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/message"
 	"github.com/yokeTH/chat-app-backend/pkg/apperror"
@@ -13,12 +14,14 @@ import (
 type messageHandler struct {
 	msgUseCase message.MessageUseCase
 	dto        dto.MessageDto
+	mServer    websocket.MessageServer
 }
 
-func NewMessageHandler(msgUseCase message.MessageUseCase, dto dto.MessageDto) *messageHandler {
+func NewMessageHandler(msgUseCase message.MessageUseCase, dto dto.MessageDto, mServer websocket.MessageServer) *messageHandler {
 	return &messageHandler{
 		msgUseCase: msgUseCase,
 		dto:        dto,
+		mServer:    mServer,
 	}
 }
 
@@ -33,6 +36,7 @@ func NewMessageHandler(msgUseCase message.MessageUseCase, dto dto.MessageDto) *m
 //	@response 		201	{object}	dto.SuccessResponse[dto.MessageResponse]	"Created"
 //	@response		400	{object}	dto.ErrorResponse	"Bad Request"
 //	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
 //	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
 //	@Router /messages [post]
 func (h *messageHandler) HandleCreateMessage(c *fiber.Ctx) error {
@@ -46,7 +50,7 @@ func (h *messageHandler) HandleCreateMessage(c *fiber.Ctx) error {
 		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
 	}
 
-	message, err := h.msgUseCase.Create(user.ID, *body)
+	message, created, err := h.msgUseCase.Send(user.ID, *body)
 	if err != nil {
 		return err
 	}
@@ -54,6 +58,14 @@ func (h *messageHandler) HandleCreateMessage(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
+
+	// a retried send was already broadcast the first time
+	if created {
+		if err := h.mServer.BroadcastMessage(*respData); err != nil {
+			return apperror.InternalServerError(err, "broadcast error")
+		}
+	}
+
 	resp := dto.Success(respData)
 	return c.Status(fiber.StatusCreated).JSON(resp)
 }
@@ -68,13 +80,19 @@ func (h *messageHandler) HandleCreateMessage(c *fiber.Ctx) error {
 //	@Param 			id	path	string	true	"Message ID"
 //	@response 		200	{object}	dto.SuccessResponse[dto.MessageResponse]	"OK"
 //	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
 //	@response		404	{object}	dto.ErrorResponse	"Not Found"
 //	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
 //	@Router /messages/{id} [get]
 func (h *messageHandler) HandleGetMessage(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	message, err := h.msgUseCase.GetByID(id)
+	user, ok := c.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
+	}
+
+	message, err := h.msgUseCase.GetUserMessage(id, user.ID)
 	if err != nil {
 		return err
 	}
@@ -100,13 +118,19 @@ func (h *messageHandler) HandleGetMessage(c *fiber.Ctx) error {
 //	@response		200	{object}	dto.PaginationResponse[dto.MessageResponse]	"OK"
 //	@response		400	{object}	dto.ErrorResponse	"Bad Request"
 //	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
 //	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
 //	@Router /conversations/{conversationID}/messages [get]
 func (h *messageHandler) HandleListMessagesByConversation(c *fiber.Ctx) error {
-	convoID := c.Params("conversation_id")
+	convoID := c.Params("conversationID")
 	page, limit := extractPaginationControl(c)
 
-	messages, last, total, err := h.msgUseCase.GetByConversationPaginated(convoID, limit, page)
+	user, ok := c.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
+	}
+
+	messages, last, total, err := h.msgUseCase.GetByConversationPaginated(convoID, user.ID, limit, page)
 	if err != nil {
 		return err
 	}
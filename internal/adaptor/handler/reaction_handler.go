@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/reaction"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+type reactionHandler struct {
+	reactionUC reaction.ReactionUseCase
+	dto        dto.ReactionDto
+	mServer    websocket.MessageServer
+}
+
+func NewReactionHandler(reactionUC reaction.ReactionUseCase, dto dto.ReactionDto, mServer websocket.MessageServer) *reactionHandler {
+	return &reactionHandler{
+		reactionUC: reactionUC,
+		dto:        dto,
+		mServer:    mServer,
+	}
+}
+
+// AddReaction godoc
+//
+//	@summary		Add reaction
+//	@description	React to a message with an emoji
+//	@tags			reaction
+//	@Security		Bearer
+//	@accept			json
+//	@produce		json
+//	@Param			id			path	string					true	"Message ID"
+//	@param			reaction	body	dto.ReactionRequest		true	"Reaction Data"
+//	@response		201	{object}	dto.SuccessResponse[dto.MessageReactionsResponse]	"Created"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
+//	@response		404	{object}	dto.ErrorResponse	"Not Found"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /messages/{id}/reactions [post]
+func (h *reactionHandler) HandleAddReaction(c *fiber.Ctx) error {
+	body := new(dto.ReactionRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, err.Error())
+	}
+
+	user, ok := c.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
+	}
+
+	message, err := h.reactionUC.AddReaction(user.ID, c.Params("id"), body.Emoji)
+	if err != nil {
+		return err
+	}
+
+	respData := h.dto.ToMessageReactionsResponse(message)
+	if err := h.mServer.BroadcastReactions(websocket.EventTypeReactionAdd, *respData); err != nil {
+		return apperror.InternalServerError(err, "broadcast error")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto.Success(respData))
+}
+
+// RemoveReaction godoc
+//
+//	@summary		Remove reaction
+//	@description	Remove an emoji reaction from a message
+//	@tags			reaction
+//	@Security		Bearer
+//	@accept			json
+//	@produce		json
+//	@Param			id			path	string					true	"Message ID"
+//	@param			reaction	body	dto.ReactionRequest		true	"Reaction Data"
+//	@response		200	{object}	dto.SuccessResponse[dto.MessageReactionsResponse]	"OK"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		403	{object}	dto.ErrorResponse	"Forbidden"
+//	@response		404	{object}	dto.ErrorResponse	"Not Found"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /messages/{id}/reactions [delete]
+func (h *reactionHandler) HandleRemoveReaction(c *fiber.Ctx) error {
+	body := new(dto.ReactionRequest)
+	if err := c.BodyParser(body); err != nil {
+		return apperror.BadRequestError(err, err.Error())
+	}
+
+	user, ok := c.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
+	}
+
+	message, err := h.reactionUC.RemoveReaction(user.ID, c.Params("id"), body.Emoji)
+	if err != nil {
+		return err
+	}
+
+	respData := h.dto.ToMessageReactionsResponse(message)
+	if err := h.mServer.BroadcastReactions(websocket.EventTypeReactionRemove, *respData); err != nil {
+		return apperror.InternalServerError(err, "broadcast error")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(dto.Success(respData))
+}
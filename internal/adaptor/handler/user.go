@@ -3,6 +3,7 @@ package handler
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
@@ -29,7 +30,7 @@ func NewUserHandler(userUC user.UserUseCase, dto dto.UserDto, mServer websocket.
 // GetUsers godoc
 //
 //	@summary		GetUsers
-//	@description	get users
+//	@description	get users, the presence of users that are not a contact of the current user is hidden
 //	@tags			user
 //	@Security		Bearer
 //	@produce		json
@@ -42,7 +43,13 @@ func NewUserHandler(userUC user.UserUseCase, dto dto.UserDto, mServer websocket.
 //	@Router /users [get]
 func (h *userHandler) HandleListUser(c *fiber.Ctx) error {
 	page, limit := extractPaginationControl(c)
-	users, last, total, err := h.userUC.List(page, limit)
+
+	user, ok := c.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
+	}
+
+	users, last, total, err := h.userUC.List(user.ID, page, limit)
 	if err != nil {
 		return err
 	}
@@ -123,3 +130,63 @@ func (h *userHandler) HandleGetMe(c *fiber.Ctx) error {
 
 	return c.Status(200).JSON(resp)
 }
+
+// GetPresence godoc
+//
+//	@summary 		Get Presence
+//	@description	get presence and last seen time of users, ids that are not a contact of the current user are left out
+//	@tags 			user
+//	@Security		Bearer
+//	@produce		json
+//	@Param			ids	query	string	true	"Comma separated user IDs"
+//	@response 		200	{object}	dto.SuccessResponse[[]dto.PresenceResponse]	"OK"
+//	@response		400	{object}	dto.ErrorResponse	"Bad Request"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /users/presence [get]
+func (h *userHandler) HandleGetPresence(c *fiber.Ctx) error {
+	var ids []string
+	for _, id := range strings.Split(c.Query("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	user, ok := c.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
+	}
+
+	users, err := h.userUC.GetPresence(user.ID, ids)
+	if err != nil {
+		return err
+	}
+
+	respData := h.dto.ToPresenceResponseList(*users)
+	resp := dto.Success(respData)
+
+	return c.Status(200).JSON(resp)
+}
+
+// ReportActivity godoc
+//
+//	@summary 		Report Activity
+//	@description	keep the user from going away, for clients on the read-only event stream that cannot send activity frames
+//	@tags 			user
+//	@Security		Bearer
+//	@response 		204	"No Content"
+//	@response		401	{object}	dto.ErrorResponse	"Unauthorized"
+//	@response		500	{object}	dto.ErrorResponse	"Internal Server Error"
+//	@Router /users/me/activity [post]
+func (h *userHandler) HandleActivity(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
+	}
+
+	if err := h.mServer.RecordActivity(user.ID); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
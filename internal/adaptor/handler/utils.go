@@ -1,19 +1,10 @@
 package handler
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+)
 
 func extractPaginationControl(c *fiber.Ctx) (int, int) {
-	page := c.QueryInt("page", 1)
-	if page <= 0 {
-		page = 1
-	}
-
-	limit := c.QueryInt("limit", 10)
-	if limit <= 0 {
-		limit = 10
-	} else if limit > 50 {
-		limit = 50
-	}
-
-	return page, limit
+	return dto.PageBounds(c.QueryInt("page", 1), c.QueryInt("limit", dto.DefaultPageLimit))
 }
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+type adminMiddleware struct{}
+
+// NewAdminMiddleware grants admin access to the users with the admin role
+// whose email is verified.
+func NewAdminMiddleware() *adminMiddleware {
+	return &adminMiddleware{}
+}
+
+// RequireAdmin must run after Auth.
+func (a *adminMiddleware) RequireAdmin(ctx *fiber.Ctx) error {
+	user, ok := ctx.Locals("user").(*domain.User)
+	if !ok {
+		return apperror.InternalServerError(errors.New("failed to retrieve user from context"), "unable to retrieve user from context")
+	}
+
+	if !user.IsAdmin() || !user.EmailVerified() {
+		return apperror.ForbiddenError(errors.New("user is not an admin"), "admin access required")
+	}
+	return ctx.Next()
+}
@@ -0,0 +1,63 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/middleware"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		description  string
+		user         *domain.User
+		expectedCode int
+	}{
+		{
+			description:  "admin",
+			user:         &domain.User{ID: "admin", Role: domain.RoleAdmin, Provider: domain.ProviderGoogle},
+			expectedCode: fiber.StatusOK,
+		},
+		{
+			description:  "admin with an unverified email",
+			user:         &domain.User{ID: "admin", Role: domain.RoleAdmin, Provider: domain.ProviderLocal},
+			expectedCode: fiber.StatusForbidden,
+		},
+		{
+			description:  "regular user",
+			user:         &domain.User{ID: "user", Role: domain.RoleUser},
+			expectedCode: fiber.StatusForbidden,
+		},
+		{
+			description:  "no role",
+			user:         &domain.User{ID: "user", Email: "admin@example.com"},
+			expectedCode: fiber.StatusForbidden,
+		},
+		{
+			description:  "no user",
+			expectedCode: fiber.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		app := fiber.New(fiber.Config{ErrorHandler: apperror.ErrorHandler})
+		app.Use(func(c *fiber.Ctx) error {
+			if test.user != nil {
+				c.Locals("user", test.user)
+			}
+			return c.Next()
+		})
+		app.Get("/admin", middleware.NewAdminMiddleware().RequireAdmin, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req, _ := http.NewRequest("GET", "/admin", nil)
+		res, err := app.Test(req, -1)
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, test.expectedCode, res.StatusCode, test.description)
+	}
+}
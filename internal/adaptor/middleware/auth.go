@@ -1,69 +1,78 @@
 package middleware
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"net/http"
 	"strings"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/google"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/session"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/user"
 	"github.com/yokeTH/chat-app-backend/pkg/apperror"
 )
 
 type authMiddleware struct {
-	userUseCase user.UserUseCase
+	userUseCase    user.UserUseCase
+	sessionUseCase session.SessionUseCase
+	verifier       google.TokenVerifier
 }
 
-func NewAuthMiddleware(userUseCase user.UserUseCase) *authMiddleware {
-	return &authMiddleware{userUseCase: userUseCase}
+func NewAuthMiddleware(userUseCase user.UserUseCase, sessionUseCase session.SessionUseCase, verifier google.TokenVerifier) *authMiddleware {
+	return &authMiddleware{userUseCase: userUseCase, sessionUseCase: sessionUseCase, verifier: verifier}
 }
 
+// Auth accepts the access tokens issued by /auth/google and /auth/refresh,
+// they are checked locally, without calling Google. The ID of the session
+// the token belongs to is stored in the session local.
 func (a *authMiddleware) Auth(ctx *fiber.Ctx) error {
-	authHeader := ctx.Get("Authorization")
-
-	if authHeader == "" {
-		return apperror.UnauthorizedError(errors.New("request without authorization header"), "Authorization header is required")
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return err
 	}
 
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		return apperror.UnauthorizedError(errors.New("invalid authorization header"), "Authorization header is invalid")
+	claims, err := a.sessionUseCase.Authenticate(token)
+	if err != nil {
+		return err
 	}
 
-	token := authHeader[7:]
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", "https://www.googleapis.com/oauth2/v3/userinfo", nil)
+	user, err := a.userUseCase.GetByID(claims.UserID)
 	if err != nil {
-		return apperror.UnauthorizedError(err, "failed to create request to Google OAuth")
+		return apperror.UnauthorizedError(err, "User no longer exists")
 	}
+	ctx.Locals("user", user)
+	ctx.Locals("session", claims.SessionID)
+	return ctx.Next()
+}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := client.Do(req)
+// GoogleAuth accepts a Google token, it only guards the login endpoint.
+func (a *authMiddleware) GoogleAuth(ctx *fiber.Ctx) error {
+	token, err := bearerToken(ctx)
 	if err != nil {
-		return apperror.UnauthorizedError(err, "failed to get profile from Google OAuth")
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return apperror.UnauthorizedError(fmt.Errorf("non-200 response from Google OAuth: %s", resp.Status), "Failed to get profile from Google OAuth")
+	profile, err := a.verifier.Verify(ctx.Context(), token)
+	if err != nil {
+		if errors.Is(err, google.ErrInvalidToken) {
+			return apperror.UnauthorizedError(err, "Invalid Google token")
+		}
+		return apperror.UnauthorizedError(err, "Failed to get profile from Google OAuth")
 	}
 
-	var profile domain.Profile
-	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
-		return apperror.UnauthorizedError(err, "failed to decode profile from Google OAuth")
-	}
+	ctx.Locals("profile", *profile)
+	return ctx.Next()
+}
 
-	ctx.Locals("profile", profile)
+func bearerToken(ctx *fiber.Ctx) (string, error) {
+	authHeader := ctx.Get("Authorization")
 
-	user, err := a.userUseCase.GoogleLogin(profile)
-	if err != nil {
-		return err
+	if authHeader == "" {
+		return "", apperror.UnauthorizedError(errors.New("request without authorization header"), "Authorization header is required")
 	}
-	ctx.Locals("user", user)
-	return ctx.Next()
+
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", apperror.UnauthorizedError(errors.New("invalid authorization header"), "Authorization header is invalid")
+	}
+
+	return authHeader[7:], nil
 }
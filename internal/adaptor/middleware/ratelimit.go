@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+// RateLimitConfig limits the auth endpoints anyone can call, per client IP.
+// Every replica counts on its own. A Max of zero turns a limit off.
+type RateLimitConfig struct {
+	LoginMax    int           `env:"LOGIN_MAX" envDefault:"10"`
+	LoginWindow time.Duration `env:"LOGIN_WINDOW" envDefault:"1m"`
+	// the mail limit is shared by every endpoint that mails a link
+	MailMax    int           `env:"MAIL_MAX" envDefault:"5"`
+	MailWindow time.Duration `env:"MAIL_WINDOW" envDefault:"15m"`
+}
+
+type rateLimitMiddleware struct {
+	login fiber.Handler
+	mail  fiber.Handler
+}
+
+func NewRateLimitMiddleware(config RateLimitConfig) *rateLimitMiddleware {
+	return &rateLimitMiddleware{
+		login: newLimiter(config.LoginMax, config.LoginWindow),
+		mail:  newLimiter(config.MailMax, config.MailWindow),
+	}
+}
+
+// Login limits the password attempts of a client, along with its attempts
+// to redeem a mailed verification or reset token.
+func (m *rateLimitMiddleware) Login(ctx *fiber.Ctx) error {
+	return m.login(ctx)
+}
+
+// Mail limits the requests of a client that mail a link to an address.
+func (m *rateLimitMiddleware) Mail(ctx *fiber.Ctx) error {
+	return m.mail(ctx)
+}
+
+func newLimiter(max int, window time.Duration) fiber.Handler {
+	if max <= 0 {
+		return func(ctx *fiber.Ctx) error {
+			return ctx.Next()
+		}
+	}
+
+	return limiter.New(limiter.Config{
+		Max:        max,
+		Expiration: window,
+		LimitReached: func(ctx *fiber.Ctx) error {
+			return apperror.New(fiber.StatusTooManyRequests, "too many requests, try again later", fmt.Errorf("rate limit of %d per %s reached by %s", max, window, ctx.IP()))
+		},
+	})
+}
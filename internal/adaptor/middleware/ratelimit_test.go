@@ -0,0 +1,45 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/middleware"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		description   string
+		config        middleware.RateLimitConfig
+		expectedCodes []int
+	}{
+		{
+			description:   "limited",
+			config:        middleware.RateLimitConfig{LoginMax: 2, LoginWindow: time.Minute},
+			expectedCodes: []int{fiber.StatusOK, fiber.StatusOK, fiber.StatusTooManyRequests},
+		},
+		{
+			description:   "off",
+			config:        middleware.RateLimitConfig{LoginWindow: time.Minute},
+			expectedCodes: []int{fiber.StatusOK, fiber.StatusOK, fiber.StatusOK},
+		},
+	}
+
+	for _, test := range tests {
+		app := fiber.New(fiber.Config{ErrorHandler: apperror.ErrorHandler})
+		app.Post("/login", middleware.NewRateLimitMiddleware(test.config).Login, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		for _, expectedCode := range test.expectedCodes {
+			req, _ := http.NewRequest("POST", "/login", nil)
+			res, err := app.Test(req, -1)
+			assert.Nilf(t, err, test.description)
+			assert.Equalf(t, expectedCode, res.StatusCode, test.description)
+		}
+	}
+}
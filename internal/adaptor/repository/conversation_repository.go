@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 
@@ -29,12 +30,19 @@ func (r *conversationRepository) GetUserConversations(userID string, limit, page
 		Where("(conversations.is_group = ? OR (conversations.is_group = ? AND conversation_members.user_id = ?))", true, false, userID).
 		Scopes(db.Paginate(&domain.Conversation{}, &limit, &page, &total, &last)).
 		Preload("Members").
+		Preload("Memberships").
 		Find(&conversations).
 		Error; err != nil {
 		return nil, 0, 0, apperror.InternalServerError(err, "fail to retrieve conversation")
 	}
 
 	for i := range conversations {
+		// groups are listed to non-members so they can join, but without their messages
+		if !hasMember(conversations[i].Members, userID) {
+			conversations[i].Memberships = nil
+			continue
+		}
+
 		var lastMessage []domain.Message
 		if err := r.db.
 			Where("conversation_id = ?", conversations[i].ID).
@@ -42,6 +50,7 @@ func (r *conversationRepository) GetUserConversations(userID string, limit, page
 			// Limit(10).
 			Preload("Sender").
 			Preload("Attachments").
+			Preload("Reactions.User").
 			Find(&lastMessage).Error; err != nil {
 			return nil, 0, 0, apperror.InternalServerError(err, "fail to retrieve last message")
 		}
@@ -49,6 +58,12 @@ func (r *conversationRepository) GetUserConversations(userID string, limit, page
 			return lastMessage[i].CreatedAt.Before(lastMessage[j].CreatedAt)
 		})
 		conversations[i].Messages = lastMessage
+
+		unread, err := r.CountUnread(conversations[i].ID, userID)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		conversations[i].UnreadCount = unread
 	}
 
 	return &conversations, last, total, nil
@@ -76,6 +91,7 @@ func (r *conversationRepository) GetUserNotInConversations(userID string, limit,
 			// Limit(10).
 			Preload("Sender").
 			Preload("Attachments").
+			Preload("Reactions.User").
 			Find(&lastMessage).Error; err != nil {
 			return nil, 0, 0, apperror.InternalServerError(err, "fail to retrieve last message")
 		}
@@ -137,6 +153,7 @@ func (r *conversationRepository) GetConversation(id string) (*domain.Conversatio
 	if err := r.db.
 		Where("id = ?", id).
 		Preload("Members").
+		Preload("Memberships").
 		First(&conversation).
 		Error; err != nil {
 		return nil, apperror.InternalServerError(err, "fail to retrieve conversation")
@@ -149,6 +166,7 @@ func (r *conversationRepository) GetConversation(id string) (*domain.Conversatio
 		// Limit(10).
 		Preload("Sender").
 		Preload("Attachments").
+		Preload("Reactions.User").
 		Find(&lastMessage).Error; err != nil {
 		return nil, apperror.InternalServerError(err, "fail to retrieve last message")
 	}
@@ -172,3 +190,107 @@ func (r *conversationRepository) AddMemberToConversation(conversationID, userID
 
 	return nil
 }
+
+// MarkAsRead moves the member's last read pointer to messageID. The pointer
+// only ever moves forward, so a late receipt for an older message is ignored.
+func (r *conversationRepository) MarkAsRead(conversationID, userID, messageID string) (*domain.ConversationMember, error) {
+	var message domain.Message
+	if err := r.db.
+		Select("id", "created_at").
+		Where("id = ? AND conversation_id = ?", messageID, conversationID).
+		First(&message).
+		Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFoundError(err, "message not found in conversation")
+		}
+		return nil, apperror.InternalServerError(err, "failed to retrieve message")
+	}
+
+	if err := r.db.
+		Model(&domain.ConversationMember{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Where("last_read_at IS NULL OR last_read_at < ?", message.CreatedAt).
+		Updates(map[string]any{
+			"last_read_message_id": message.ID,
+			"last_read_at":         message.CreatedAt,
+		}).Error; err != nil {
+		return nil, apperror.InternalServerError(err, "failed to update last read message")
+	}
+
+	var member domain.ConversationMember
+	if err := r.db.
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		First(&member).
+		Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.ForbiddenError(err, "not a member of this conversation")
+		}
+		return nil, apperror.InternalServerError(err, "failed to retrieve member")
+	}
+
+	return &member, nil
+}
+
+// CountUnread counts messages from other members that arrived after the
+// user's last read message. Non-members always have zero unread messages.
+func (r *conversationRepository) CountUnread(conversationID, userID string) (int, error) {
+	var count int64
+	if err := r.db.
+		Table("messages").
+		Joins("JOIN conversation_members ON conversation_members.conversation_id = messages.conversation_id AND conversation_members.user_id = ?", userID).
+		Where("messages.conversation_id = ? AND messages.is_deleted = false", conversationID).
+		Where("messages.sender_id IS NOT NULL AND messages.sender_id <> ?", userID).
+		Where("conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at").
+		Count(&count).
+		Error; err != nil {
+		return 0, apperror.InternalServerError(err, "failed to count unread messages")
+	}
+	return int(count), nil
+}
+
+func (r *conversationRepository) GetConversationByID(id string) (*domain.Conversation, error) {
+	var conversation domain.Conversation
+	if err := r.db.Where("id = ?", id).First(&conversation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFoundError(err, "conversation not found")
+		}
+		return nil, apperror.InternalServerError(err, "fail to retrieve conversation")
+	}
+	return &conversation, nil
+}
+
+func (r *conversationRepository) IsMember(conversationID, userID string) (bool, error) {
+	var count int64
+	if err := r.db.
+		Model(&domain.ConversationMember{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
+		Count(&count).
+		Error; err != nil {
+		return false, apperror.InternalServerError(err, "failed to check conversation membership")
+	}
+	return count > 0, nil
+}
+
+// GetContactIDs returns the users that share at least one conversation with userID.
+func (r *conversationRepository) GetContactIDs(userID string) ([]string, error) {
+	var ids []string
+	if err := r.db.
+		Table("conversation_members AS me").
+		Distinct("other.user_id").
+		Joins("JOIN conversation_members AS other ON other.conversation_id = me.conversation_id").
+		Where("me.user_id = ? AND other.user_id <> ?", userID, userID).
+		Pluck("other.user_id", &ids).
+		Error; err != nil {
+		return nil, apperror.InternalServerError(err, "failed to retrieve contacts")
+	}
+	return ids, nil
+}
+
+func hasMember(members []domain.User, userID string) bool {
+	for _, member := range members {
+		if member.ID == userID {
+			return true
+		}
+	}
+	return false
+}
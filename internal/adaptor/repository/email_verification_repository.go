@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+	"gorm.io/gorm"
+)
+
+type emailVerificationRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailVerificationRepository(db *gorm.DB) *emailVerificationRepository {
+	return &emailVerificationRepository{db: db}
+}
+
+func (r *emailVerificationRepository) Create(verification *domain.EmailVerification) error {
+	if err := r.db.Create(verification).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to create email verification")
+	}
+	return nil
+}
+
+// FindByTokenHash returns the verification whose token hashes to hash, or nil if there is none.
+func (r *emailVerificationRepository) FindByTokenHash(hash string) (*domain.EmailVerification, error) {
+	var verification domain.EmailVerification
+	if err := r.db.Where("token_hash = ?", hash).First(&verification).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, apperror.InternalServerError(err, "failed to retrieve email verification")
+	}
+	return &verification, nil
+}
+
+// Use marks a verification as used. It reports false when the verification
+// was already used, so two requests racing with the same token cannot both succeed.
+func (r *emailVerificationRepository) Use(id string) (bool, error) {
+	result := r.db.
+		Model(&domain.EmailVerification{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, apperror.InternalServerError(result.Error, "failed to use email verification")
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+	"gorm.io/gorm"
+)
+
+type eventRepository struct {
+	db *gorm.DB
+}
+
+func NewEventRepository(db *gorm.DB) *eventRepository {
+	return &eventRepository{db: db}
+}
+
+// nextSeq hands out the next sequence number of the conversation. It must run
+// inside the transaction that persists the sequenced row, the row lock taken
+// by the update keeps sequences gap free and ordered.
+func nextSeq(tx *gorm.DB, conversationID string) (int64, error) {
+	var seq int64
+	result := tx.Raw("UPDATE conversations SET last_seq = last_seq + 1 WHERE id = ? RETURNING last_seq", conversationID).Scan(&seq)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return seq, nil
+}
+
+func (r *eventRepository) Create(event *domain.ConversationEvent) error {
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		seq, err := nextSeq(tx, event.ConversationID)
+		if err != nil {
+			return err
+		}
+		event.Seq = seq
+		return tx.Create(event).Error
+	}); err != nil {
+		return apperror.InternalServerError(err, "failed to create conversation event")
+	}
+	return nil
+}
+
+func (r *eventRepository) FindAfterSeq(conversationID string, seq int64, limit int) (*[]domain.ConversationEvent, error) {
+	var events []domain.ConversationEvent
+
+	if err := r.db.
+		Where("conversation_id = ? AND seq > ?", conversationID, seq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return nil, apperror.InternalServerError(err, "failed to find conversation events")
+	}
+	return &events, nil
+}
+
+// Prune deletes the events created before the given time. Each conversation
+// remembers the last sequence it lost, so a replay reaching back that far is
+// known to be incomplete.
+func (r *eventRepository) Prune(before time.Time) (int64, error) {
+	var deleted int64
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			UPDATE conversations SET pruned_seq = pruned.seq
+			FROM (
+				SELECT conversation_id, MAX(seq) AS seq FROM conversation_events
+				WHERE created_at < ? GROUP BY conversation_id
+			) AS pruned
+			WHERE conversations.id = pruned.conversation_id AND conversations.pruned_seq < pruned.seq`, before).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("created_at < ?", before).Delete(&domain.ConversationEvent{})
+		deleted = result.RowsAffected
+		return result.Error
+	}); err != nil {
+		return 0, apperror.InternalServerError(err, "failed to prune conversation events")
+	}
+	return deleted, nil
+}
@@ -18,7 +18,17 @@ func NewMessageRepository(db *gorm.DB) *messageRepository {
 }
 
 func (r *messageRepository) Create(message *domain.Message) error {
-	if err := r.db.Create(message).Error; err != nil {
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		seq, err := nextSeq(tx, message.ConversationID)
+		if err != nil {
+			return err
+		}
+		message.Seq = seq
+		return tx.Create(message).Error
+	}); err != nil {
+		if db.IsDuplicatedKey(r.db, err) {
+			return apperror.ConflictError(err, "message already exists")
+		}
 		return apperror.InternalServerError(err, "failed to create message")
 	}
 	return nil
@@ -27,20 +37,38 @@ func (r *messageRepository) Create(message *domain.Message) error {
 func (r *messageRepository) FindByID(id string) (*domain.Message, error) {
 	var message domain.Message
 
-	if err := r.db.Preload("Sender").Preload("Conversation").Preload("Attachments").Preload("Reactions").First(&message, "id = ?", id).Error; err != nil {
+	if err := r.db.Preload("Sender").Preload("Conversation").Preload("Attachments").Preload("Reactions.User").First(&message, "id = ?", id).Error; err != nil {
 		return nil, apperror.InternalServerError(err, "failed to find message by id")
 	}
 
 	return &message, nil
 }
 
+func (r *messageRepository) FindByClientMessageID(senderID, clientMessageID string) (*domain.Message, error) {
+	var message domain.Message
+
+	if err := r.db.
+		Preload("Sender").
+		Preload("Attachments").
+		Preload("Reactions.User").
+		Where("sender_id = ? AND client_message_id = ?", senderID, clientMessageID).
+		First(&message).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFoundError(err, "message not found")
+		}
+		return nil, apperror.InternalServerError(err, "failed to find message by client message id")
+	}
+
+	return &message, nil
+}
+
 func (r *messageRepository) FindByConversationID(conversationID string) (*[]domain.Message, error) {
 	var messages []domain.Message
 
 	if err := r.db.
 		Preload("Sender").
 		Preload("Attachments").
-		Preload("Reactions").
+		Preload("Reactions.User").
 		Where("conversation_id = ? AND is_deleted = false", conversationID).
 		Order("created_at ASC").
 		Find(&messages).Error; err != nil {
@@ -79,6 +107,7 @@ func (r *messageRepository) FindByConversationIDPaginated(convoID string, limit,
 		Order("created_at ASC").
 		Preload("Sender").
 		Preload("Attachments").
+		Preload("Reactions.User").
 		Scopes(db.Paginate(&domain.Message{}, &limit, &page, &total, &last))
 
 	if err := query.Find(&messages).Error; err != nil {
@@ -90,3 +119,29 @@ func (r *messageRepository) FindByConversationIDPaginated(convoID string, limit,
 
 	return &messages, last, total, nil
 }
+
+// FindAfterSeq includes deleted messages, a resuming client would otherwise
+// see a hole in the sequence. They are returned as tombstones without
+// content, attachments or reactions.
+func (r *messageRepository) FindAfterSeq(conversationID string, seq int64, limit int) (*[]domain.Message, error) {
+	var messages []domain.Message
+
+	if err := r.db.
+		Preload("Sender").
+		Preload("Attachments").
+		Preload("Reactions.User").
+		Where("conversation_id = ? AND seq > ?", conversationID, seq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&messages).Error; err != nil {
+		return nil, apperror.InternalServerError(err, "failed to find messages after sequence")
+	}
+	for i := range messages {
+		if messages[i].IsDeleted {
+			messages[i].Content = ""
+			messages[i].Attachments = nil
+			messages[i].Reactions = nil
+		}
+	}
+	return &messages, nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+	"gorm.io/gorm"
+)
+
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) *passwordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+func (r *passwordResetRepository) Create(reset *domain.PasswordReset) error {
+	if err := r.db.Create(reset).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to create password reset")
+	}
+	return nil
+}
+
+// FindByTokenHash returns the reset whose token hashes to hash, or nil if there is none.
+func (r *passwordResetRepository) FindByTokenHash(hash string) (*domain.PasswordReset, error) {
+	var reset domain.PasswordReset
+	if err := r.db.Where("token_hash = ?", hash).First(&reset).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, apperror.InternalServerError(err, "failed to retrieve password reset")
+	}
+	return &reset, nil
+}
+
+// Use marks a reset as used. It reports false when the reset was already
+// used, so two requests racing with the same token cannot both succeed.
+func (r *passwordResetRepository) Use(id string) (bool, error) {
+	result := r.db.
+		Model(&domain.PasswordReset{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, apperror.InternalServerError(result.Error, "failed to use password reset")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UseByUserID marks every reset of a user that is still unused as used.
+func (r *passwordResetRepository) UseByUserID(userID string) error {
+	if err := r.db.
+		Model(&domain.PasswordReset{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to use password resets")
+	}
+	return nil
+}
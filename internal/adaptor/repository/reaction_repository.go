@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type reactionRepository struct {
+	db *gorm.DB
+}
+
+func NewReactionRepository(db *gorm.DB) *reactionRepository {
+	return &reactionRepository{db: db}
+}
+
+func (r *reactionRepository) Create(reaction *domain.Reaction) error {
+	// the same user reacting with the same emoji twice is a no-op
+	if err := r.db.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(reaction).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to create reaction")
+	}
+	return nil
+}
+
+func (r *reactionRepository) Delete(messageID, userID, emoji string) error {
+	if err := r.db.
+		Where("message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji).
+		Delete(&domain.Reaction{}).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to delete reaction")
+	}
+	return nil
+}
+
+func (r *reactionRepository) FindByMessageID(messageID string) (*[]domain.Reaction, error) {
+	var reactions []domain.Reaction
+
+	if err := r.db.
+		Preload("User").
+		Where("message_id = ?", messageID).
+		Order("created_at ASC").
+		Find(&reactions).Error; err != nil {
+		return nil, apperror.InternalServerError(err, "failed to find reactions by message id")
+	}
+	return &reactions, nil
+}
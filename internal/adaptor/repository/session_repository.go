@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+	"gorm.io/gorm"
+)
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *sessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(session *domain.Session) error {
+	if err := r.db.Create(session).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to create session")
+	}
+	return nil
+}
+
+// FindByID returns the session with the given ID, or nil if there is none.
+func (r *sessionRepository) FindByID(id string) (*domain.Session, error) {
+	return r.findBy("id = ?", id)
+}
+
+// FindByTokenHash returns the session whose current refresh token hashes to
+// hash, or nil if there is none.
+func (r *sessionRepository) FindByTokenHash(hash string) (*domain.Session, error) {
+	return r.findBy("token_hash = ?", hash)
+}
+
+// FindByPreviousTokenHash returns the session whose last replaced refresh
+// token hashes to hash, or nil if there is none.
+func (r *sessionRepository) FindByPreviousTokenHash(hash string) (*domain.Session, error) {
+	return r.findBy("previous_token_hash = ?", hash)
+}
+
+func (r *sessionRepository) findBy(query string, value string) (*domain.Session, error) {
+	var session domain.Session
+	if err := r.db.Where(query, value).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, apperror.InternalServerError(err, "failed to retrieve session")
+	}
+	return &session, nil
+}
+
+// Rotate replaces the refresh token of an active session. It reports false
+// when oldHash is no longer the session's token, because a concurrent refresh
+// already replaced it or the session was revoked.
+func (r *sessionRepository) Rotate(id, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	result := r.db.
+		Model(&domain.Session{}).
+		Where("id = ? AND token_hash = ? AND revoked_at IS NULL", id, oldHash).
+		Updates(map[string]any{
+			"token_hash":          newHash,
+			"previous_token_hash": oldHash,
+			"expires_at":          expiresAt,
+		})
+	if result.Error != nil {
+		return false, apperror.InternalServerError(result.Error, "failed to rotate session")
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *sessionRepository) Revoke(id string) error {
+	if err := r.db.
+		Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to revoke session")
+	}
+	return nil
+}
+
+// RevokeByUserID revokes every active session of a user and moves the user
+// on to the next session epoch.
+func (r *sessionRepository) RevokeByUserID(userID string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Model(&domain.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.
+			Model(&domain.User{}).
+			Where("id = ?", userID).
+			Update("session_epoch", gorm.Expr("session_epoch + 1")).Error
+	})
+	if err != nil {
+		return apperror.InternalServerError(err, "failed to revoke sessions")
+	}
+	return nil
+}
+
+// Epoch returns the session epoch of a user, 0 for a user that does not exist.
+func (r *sessionRepository) Epoch(userID string) (int64, error) {
+	var epochs []int64
+	if err := r.db.
+		Model(&domain.User{}).
+		Where("id = ?", userID).
+		Pluck("session_epoch", &epochs).Error; err != nil {
+		return 0, apperror.InternalServerError(err, "failed to retrieve session epoch")
+	}
+	if len(epochs) == 0 {
+		return 0, nil
+	}
+	return epochs[0], nil
+}
@@ -2,12 +2,14 @@ package repository
 
 import (
 	"errors"
+	"time"
 
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
 	"github.com/yokeTH/chat-app-backend/internal/domain"
 	"github.com/yokeTH/chat-app-backend/pkg/apperror"
 	"github.com/yokeTH/chat-app-backend/pkg/db"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type userRepository struct {
@@ -44,13 +46,70 @@ func (r *userRepository) GetUserByProvider(provider, providerID string) (*domain
 	return &user, nil
 }
 
+func (r *userRepository) GetUserByEmail(email string) (*domain.User, error) {
+	var user domain.User
+
+	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, apperror.NotFoundError(err, "user not found")
+		}
+		return nil, apperror.InternalServerError(err, "failed to find user")
+	}
+	return &user, nil
+}
+
 func (r *userRepository) CreateUser(user *domain.User) (*domain.User, error) {
 	if err := r.db.Create(user).Error; err != nil {
+		if db.IsDuplicatedKey(r.db, err) {
+			return nil, apperror.ConflictError(err, "user already exists")
+		}
 		return nil, apperror.InternalServerError(err, "failed to create user")
 	}
 	return user, nil
 }
 
+func (r *userRepository) UpdatePasswordHash(userID string, passwordHash string) error {
+	if err := r.db.
+		Model(&domain.User{}).
+		Where("id = ?", userID).
+		Update("password_hash", passwordHash).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to update password")
+	}
+	return nil
+}
+
+func (r *userRepository) MarkEmailVerified(userID string) error {
+	if err := r.db.
+		Model(&domain.User{}).
+		Where("id = ? AND email_verified_at IS NULL", userID).
+		Update("email_verified_at", time.Now()).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to verify email")
+	}
+	return nil
+}
+
+// LinkProvider moves a user over to another sign-in provider, whose owner
+// verified the email. dropPassword removes a password that was set before
+// anyone verified the email, it may belong to someone else.
+func (r *userRepository) LinkProvider(userID, provider, providerID string, dropPassword bool) error {
+	updates := map[string]any{
+		"provider":          provider,
+		"provider_id":       providerID,
+		"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+	}
+	if dropPassword {
+		updates["password_hash"] = ""
+	}
+
+	if err := r.db.
+		Model(&domain.User{}).
+		Where("id = ?", userID).
+		Updates(updates).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to link account")
+	}
+	return nil
+}
+
 func (r *userRepository) UpdateUserInfo(userID string, updatedData dto.UpdateUserRequest) error {
 	if err := r.db.
 		Model(&domain.User{}).
@@ -61,22 +120,85 @@ func (r *userRepository) UpdateUserInfo(userID string, updatedData dto.UpdateUse
 	return nil
 }
 
+// SetIsOnline records a connect or disconnect as the user's last seen time.
+// An away user comes back online, away only lasts as long as the idle session.
 func (r *userRepository) SetIsOnline(userID string, isOnline bool) error {
-	user := domain.User{
-		IsOnline: isOnline,
+	if err := r.db.
+		Model(&domain.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]any{
+			"is_online":    isOnline,
+			"last_seen_at": time.Now(),
+			"presence":     gorm.Expr("CASE WHEN presence = ? THEN ? ELSE presence END", domain.PresenceAway, domain.PresenceOnline),
+		}).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to update user info")
 	}
 
+	return nil
+}
+
+func (r *userRepository) SetPresence(userID string, presence domain.PresenceStatus) error {
 	if err := r.db.
 		Model(&domain.User{}).
 		Where("id = ?", userID).
-		Select("IsOnline").
-		Updates(&user).Error; err != nil {
-		return apperror.InternalServerError(err, "failed to update user info")
+		Update("presence", presence).Error; err != nil {
+		return apperror.InternalServerError(err, "failed to update user presence")
 	}
 
 	return nil
 }
 
+// RecordActivity moves the user's last seen time to now and reports whether
+// this brought them back from away.
+func (r *userRepository) RecordActivity(userID string) (bool, error) {
+	now := time.Now()
+	back := r.db.
+		Model(&domain.User{}).
+		Where("id = ? AND presence = ?", userID, domain.PresenceAway).
+		Updates(map[string]any{"presence": domain.PresenceOnline, "last_seen_at": now})
+	if back.Error != nil {
+		return false, apperror.InternalServerError(back.Error, "failed to update user presence")
+	}
+	if back.RowsAffected > 0 {
+		return true, nil
+	}
+
+	if err := r.db.
+		Model(&domain.User{}).
+		Where("id = ?", userID).
+		Update("last_seen_at", now).Error; err != nil {
+		return false, apperror.InternalServerError(err, "failed to update last seen")
+	}
+	return false, nil
+}
+
+// MarkIdleAway moves online users among userIDs that have not been seen since
+// idleSince to away, and returns the IDs of the users it moved.
+func (r *userRepository) MarkIdleAway(userIDs []string, idleSince time.Time) ([]string, error) {
+	var users []domain.User
+	if err := r.db.
+		Model(&users).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("id IN ? AND is_online = ? AND presence = ? AND last_seen_at < ?", userIDs, true, domain.PresenceOnline, idleSince).
+		Update("presence", domain.PresenceAway).Error; err != nil {
+		return nil, apperror.InternalServerError(err, "failed to update user presence")
+	}
+
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids, nil
+}
+
+func (r *userRepository) GetUsersByIDs(ids []string) (*[]domain.User, error) {
+	var users []domain.User
+	if err := r.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, apperror.InternalServerError(err, "failed to get users")
+	}
+	return &users, nil
+}
+
 func (r *userRepository) ListUser(page, limit int) (*[]domain.User, int, int, error) {
 	var users []domain.User
 	var total, last int
@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/yokeTH/chat-app-backend/pkg/backplane"
+)
+
+// backplaneKindDisconnect tags disconnect commands, their payload is a
+// disconnectCommand instead of a frame for the client.
+const backplaneKindDisconnect = "disconnect"
+
+const (
+	TransportWebSocket = "websocket"
+	TransportSSE       = "sse"
+)
+
+const (
+	disconnectReason   = "disconnected by an administrator"
+	sessionEndedReason = "session ended"
+)
+
+// ConnectionInfo describes a live connection held by this replica, other
+// replicas hold connections that are never listed here. ID is generated
+// by the server when the connection is opened.
+type ConnectionInfo struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Transport   string    `json:"transport"`
+	ConnectedAt time.Time `json:"connected_at"`
+	QueueDepth  int       `json:"queue_depth"`
+}
+
+// ConnectionStats groups the counters of this replica.
+type ConnectionStats struct {
+	Queue  QueueStats               `json:"queue"`
+	Events map[EventType]EventStats `json:"events"`
+}
+
+type disconnectCommand struct {
+	ConnectionID string `json:"connection_id,omitempty"`
+	SessionID    string `json:"session_id,omitempty"`
+}
+
+// Connections lists the connections held by this replica, only those of
+// userID when it is not empty, oldest first. It does not ask the other
+// replicas, a user connected elsewhere is missing from the list.
+func (s *messageServer) Connections(userID string) []ConnectionInfo {
+	var clients []*client
+	if userID == "" {
+		clients = s.allClients()
+	} else {
+		clients = s.getClientByUserID(userID)
+	}
+
+	connections := make([]ConnectionInfo, 0, len(clients))
+	for _, client := range clients {
+		connections = append(connections, client.info())
+	}
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].ConnectedAt.Before(connections[j].ConnectedAt)
+	})
+	return connections
+}
+
+// Disconnect closes the connection with the given ID on whichever replica holds it.
+func (s *messageServer) Disconnect(connectionID string) error {
+	payload, err := json.Marshal(disconnectCommand{ConnectionID: connectionID})
+	if err != nil {
+		return err
+	}
+	return s.backplane.Publish(context.Background(), backplane.Message{
+		Kind:    backplaneKindDisconnect,
+		Payload: payload,
+	})
+}
+
+// DisconnectUser closes every connection of userID on every replica.
+func (s *messageServer) DisconnectUser(userID string) error {
+	payload, err := json.Marshal(disconnectCommand{})
+	if err != nil {
+		return err
+	}
+	return s.backplane.Publish(context.Background(), backplane.Message{
+		UserIDs: []string{userID},
+		Kind:    backplaneKindDisconnect,
+		Payload: payload,
+	})
+}
+
+// DisconnectSession closes the connections opened in a login session on
+// every replica, once the session has ended.
+func (s *messageServer) DisconnectSession(sessionID string) error {
+	payload, err := json.Marshal(disconnectCommand{SessionID: sessionID})
+	if err != nil {
+		return err
+	}
+	return s.backplane.Publish(context.Background(), backplane.Message{
+		Kind:    backplaneKindDisconnect,
+		Payload: payload,
+	})
+}
+
+// SendNotice pushes a server notice to every connection of userID.
+func (s *messageServer) SendNotice(userID string, notice ServerNotice) error {
+	msg, err := newFrame(EventTypeNotice, notice)
+	if err != nil {
+		return err
+	}
+	return s.publish([]string{userID}, msg)
+}
+
+// disconnect runs a disconnect command against the connections of this replica.
+func (s *messageServer) disconnect(msg backplane.Message) {
+	var command disconnectCommand
+	if err := json.Unmarshal(msg.Payload, &command); err != nil {
+		log.Printf("invalid disconnect command: %v", err)
+		return
+	}
+
+	if command.ConnectionID != "" {
+		s.wrmu.RLock()
+		client, ok := s.clients[command.ConnectionID]
+		s.wrmu.RUnlock()
+		if ok {
+			log.Printf("disconnecting connection %s of user %s", client.id, client.userID)
+			client.closeWith(CloseDisconnected, disconnectReason)
+		}
+		return
+	}
+
+	if command.SessionID != "" {
+		for _, client := range s.allClients() {
+			if client.sessionID == command.SessionID {
+				log.Printf("closing connection %s of user %s, its session ended", client.id, client.userID)
+				client.closeWith(CloseDisconnected, sessionEndedReason)
+			}
+		}
+		return
+	}
+
+	for _, userID := range msg.UserIDs {
+		s.removeClientByUserID(userID)
+	}
+}
@@ -7,62 +7,67 @@ import (
 	"github.com/gofiber/contrib/websocket"
 )
 
-func (s *messageServer) sendMessageProcess() {
-	sendTicker := time.Tick(10 * time.Millisecond)
-	pingTicker := time.Tick(1 * time.Minute)
+const writeWait = 10 * time.Second
 
-	for {
-		select {
-		case <-sendTicker:
-			s.broadcastMessages()
+// writeProcess is the only goroutine allowed to write to the client's connection.
+// It drains the outbound queue, sends pings and closes the connection once the
+// client is closed or a write fails, which in turn unblocks the reader.
+func (c *client) writeProcess(pingInterval time.Duration) {
+	pingTicker := time.NewTicker(pingInterval)
+	defer func() {
+		pingTicker.Stop()
+		c.connection.Close()
+	}()
 
-		case <-pingTicker:
-			s.sendPings()
-		}
-	}
-}
-
-func (s *messageServer) broadcastMessages() {
-	for id, c := range s.clients {
+	for {
 		select {
-		case <-c.terminate:
-			s.removeClientByID(id)
-
-		case msg, ok := <-c.message:
-			c.mu.Lock()
-
-			if !ok || c.isClosed {
-				c.isClosed = true
-				c.mu.Unlock()
-				continue
+		case <-c.wake:
+			for {
+				f, ok := c.next()
+				if !ok {
+					break
+				}
+				_ = c.connection.SetWriteDeadline(time.Now().Add(writeWait))
+				if err := c.write(f); err != nil {
+					log.Printf("write error to user %s: %v", c.userID, err)
+					c.close()
+					return
+				}
 			}
 
-			if err := c.connection.WriteMessage(websocket.TextMessage, msg); err != nil {
-				log.Println("write error:", err)
+		case <-pingTicker.C:
+			_ = c.connection.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.connection.WriteMessage(websocket.PingMessage, []byte("ping")); err != nil {
+				log.Printf("ping failed to user %s: %v", c.userID, err)
 				c.close()
+				return
 			}
 
-			c.mu.Unlock()
-
-		default:
+		case <-c.done:
+			c.flush()
+			_ = c.connection.SetWriteDeadline(time.Now().Add(writeWait))
+			_ = c.connection.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason))
+			return
 		}
 	}
 }
 
-func (s *messageServer) sendPings() {
-	for id, c := range s.clients {
-		c.mu.Lock()
+// flush writes the frames still queued when the client was closed with a
+// flush deadline, giving up at the first error or once the deadline passes.
+func (c *client) flush() {
+	if c.flushDeadline.IsZero() {
+		return
+	}
 
-		if c.isClosed {
-			c.mu.Unlock()
-			continue
+	_ = c.connection.SetWriteDeadline(c.flushDeadline)
+	for time.Now().Before(c.flushDeadline) {
+		f, ok := c.next()
+		if !ok {
+			return
 		}
-
-		if err := c.connection.WriteMessage(websocket.PingMessage, []byte("ping")); err != nil {
-			log.Printf("Ping failed to user %s: %v", id, err)
-			c.close()
+		if err := c.write(f); err != nil {
+			log.Printf("flush error to user %s: %v", c.userID, err)
+			return
 		}
-
-		c.mu.Unlock()
 	}
 }
@@ -0,0 +1,83 @@
+package websocket
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+const (
+	// SlowConsumerDrop discards the oldest droppable frames of a full queue,
+	// a client whose queue is full of frames that must not be lost is still
+	// disconnected.
+	SlowConsumerDrop = "drop"
+	// SlowConsumerDisconnect closes a client as soon as its queue is full.
+	SlowConsumerDisconnect = "disconnect"
+)
+
+// frame is an encoded message waiting in a client's outbound queue.
+type frame struct {
+	data []byte
+	// droppable frames, such as typing and presence, are superseded by the
+	// next one and may be discarded when the client cannot keep up.
+	droppable bool
+	// encodings is shared by the copies of a frame sent to many clients
+	encodings *encodings
+}
+
+// encode returns the frame in the wire encoding of c.
+func (f frame) encode(c codec) ([]byte, error) {
+	if f.encodings == nil {
+		return c.encode(f.data)
+	}
+	return f.encodings.encode(c, f.data)
+}
+
+// QueueStats counts how the slow consumer policy was applied since start.
+type QueueStats struct {
+	DroppedFrames           int64 `json:"dropped_frames"`
+	SlowConsumerDisconnects int64 `json:"slow_consumer_disconnects"`
+}
+
+// backpressure is the outbound queue policy shared by every client of a server.
+type backpressure struct {
+	queueSize    int
+	policy       string
+	dropped      atomic.Int64
+	disconnected atomic.Int64
+}
+
+func newBackpressure(queueSize int, policy string) *backpressure {
+	if policy != SlowConsumerDisconnect {
+		policy = SlowConsumerDrop
+	}
+	return &backpressure{queueSize: queueSize, policy: policy}
+}
+
+// admit makes room for f in a full queue and returns the new queue. ok is
+// false when the client has to be disconnected instead.
+func (b *backpressure) admit(queue []frame, f frame) (_ []frame, ok bool) {
+	if b.policy == SlowConsumerDisconnect {
+		return queue, false
+	}
+
+	for i, queued := range queue {
+		if queued.droppable {
+			b.dropped.Add(1)
+			return append(queue[:i], queue[i+1:]...), true
+		}
+	}
+	return queue, false
+}
+
+func (b *backpressure) overflow(c *client) {
+	b.disconnected.Add(1)
+	log.Printf("outbound queue of user %s connection %s is full, disconnecting slow consumer", c.userID, c.id)
+	c.closeWith(CloseSlowConsumer, "slow consumer")
+}
+
+func (b *backpressure) stats() QueueStats {
+	return QueueStats{
+		DroppedFrames:           b.dropped.Load(),
+		SlowConsumerDisconnects: b.disconnected.Load(),
+	}
+}
@@ -2,31 +2,301 @@ package websocket
 
 import (
 	"sync"
+	"time"
 
 	"github.com/gofiber/contrib/websocket"
-	"github.com/yokeTH/chat-app-backend/internal/domain"
 )
 
 type client struct {
-	id         string
-	isClosed   bool
-	terminate  chan bool
-	wg         *sync.WaitGroup
-	mu         sync.Mutex
-	connection *websocket.Conn
-	message    chan []byte
-	userID     string
-	profile    domain.Profile
-}
-
-func (c *client) sendError(message string) {
-	_ = c.connection.WriteMessage(websocket.TextMessage, []byte(message))
+	id           string
+	connection   *websocket.Conn
+	codec        codec
+	backpressure *backpressure
+	// wake tells the writer goroutine that queue is not empty, space tells
+	// enqueueWait that the writer made room
+	wake      chan struct{}
+	space     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	// closeCode, closeReason and flushDeadline are set once, before done is closed
+	closeCode     int
+	closeReason   string
+	flushDeadline time.Time
+	userID        string
+	// sessionID is the login session the connection was opened in
+	sessionID   string
+	connectedAt time.Time
+	// lastActivity and the rate limiting state are only used by the reader goroutine
+	lastActivity    time.Time
+	limiters        limiters
+	userLimiters    limiters
+	violations      int
+	violationsSince time.Time
+
+	mu    sync.Mutex
+	queue []frame
+	// while holding, live frames are parked in held instead of the queue
+	holding bool
+	held    []frame
+	// subscriptions are the conversations the client is currently viewing,
+	// a client that never subscribed receives the topics of all of them
+	subscriptions map[string]struct{}
+}
+
+func newClient(id string, connection *websocket.Conn, backpressure *backpressure) *client {
+	c := newStreamClient(id, backpressure)
+	c.connection = connection
+	c.codec = codecFor(connection.Subprotocol())
+	return c
+}
+
+// newStreamClient creates a client without a socket, its frames are written
+// to an event stream by streamProcess.
+func newStreamClient(id string, backpressure *backpressure) *client {
+	return &client{
+		id:           id,
+		codec:        jsonCodec{},
+		backpressure: backpressure,
+		wake:         make(chan struct{}, 1),
+		space:        make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		connectedAt:  time.Now(),
+		// connecting already counts as activity
+		lastActivity:  time.Now(),
+		subscriptions: make(map[string]struct{}),
+	}
+}
+
+// maxHeldFrames bounds the live frames parked during a replay. A client
+// that gets more than that meanwhile is handled like a slow consumer, it
+// resumes again after reconnecting.
+const maxHeldFrames = 2 * replayLimit
+
+// send queues a live frame for the writer goroutine, or parks it while a replay is running.
+func (c *client) send(f frame) {
+	c.mu.Lock()
+	if c.holding {
+		if len(c.held) < maxHeldFrames {
+			c.held = append(c.held, f)
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		if f.droppable {
+			c.backpressure.dropped.Add(1)
+			return
+		}
+		c.backpressure.overflow(c)
+		return
+	}
+	c.mu.Unlock()
+
+	c.enqueue(f)
+}
+
+// enqueue queues a frame for the writer goroutine. It never blocks, a full
+// queue is resolved by the server's slow consumer policy.
+func (c *client) enqueue(f frame) {
+	c.mu.Lock()
+	if len(c.queue) >= c.backpressure.queueSize {
+		queue, ok := c.backpressure.admit(c.queue, f)
+		if !ok && f.droppable && c.backpressure.policy == SlowConsumerDrop {
+			// nothing older can go, the new frame is the oldest droppable one
+			c.backpressure.dropped.Add(1)
+			c.mu.Unlock()
+			return
+		}
+		if !ok {
+			c.mu.Unlock()
+			c.backpressure.overflow(c)
+			return
+		}
+		c.queue = queue
+	}
+	c.queue = append(c.queue, f)
+	c.mu.Unlock()
+
+	c.signal(c.wake)
+}
+
+// enqueueWait queues a frame that is part of a larger batch, such as a
+// replay, waiting for the writer to make room instead of applying the slow
+// consumer policy. It must only be called from the client's reader goroutine.
+func (c *client) enqueueWait(f frame) {
+	for {
+		c.mu.Lock()
+		if len(c.queue) < c.backpressure.queueSize {
+			c.queue = append(c.queue, f)
+			c.mu.Unlock()
+			c.signal(c.wake)
+			return
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.space:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// next pops the oldest queued frame.
+func (c *client) next() (frame, bool) {
+	c.mu.Lock()
+	if len(c.queue) == 0 {
+		c.mu.Unlock()
+		return frame{}, false
+	}
+	f := c.queue[0]
+	c.queue[0] = frame{}
+	c.queue = c.queue[1:]
+	c.mu.Unlock()
+
+	c.signal(c.space)
+	return f, true
+}
+
+// info describes the client for the connection administration.
+func (c *client) info() ConnectionInfo {
+	transport := TransportWebSocket
+	if c.connection == nil {
+		transport = TransportSSE
+	}
+
+	c.mu.Lock()
+	depth := len(c.queue)
+	c.mu.Unlock()
+
+	return ConnectionInfo{
+		ID:          c.id,
+		UserID:      c.userID,
+		Transport:   transport,
+		ConnectedAt: c.connectedAt,
+		QueueDepth:  depth,
+	}
+}
+
+// isClosed reports whether the client was closed by any goroutine.
+func (c *client) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *client) signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// reject writes the data frame followed by a close frame and releases the connection.
+// It must only be used before the writer goroutine is started.
+func (c *client) reject(data []byte, closeCode int, reason string) {
+	_ = c.connection.SetWriteDeadline(time.Now().Add(writeWait))
+	if data != nil {
+		_ = c.write(frame{data: data})
+	}
+	_ = c.connection.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, reason))
 	c.connection.Close()
 }
 
+// write encodes a JSON frame for the connection and writes it.
+func (c *client) write(f frame) error {
+	encoded, err := f.encode(c.codec)
+	if err != nil {
+		return err
+	}
+	return c.connection.WriteMessage(c.codec.frameType(), encoded)
+}
+
+// close asks the writer goroutine to send a normal close frame and release the connection.
+// It is safe to call more than once and from any goroutine.
 func (c *client) close() {
-	_ = c.connection.WriteMessage(websocket.CloseMessage, []byte{})
-	c.terminate <- true
-	c.isClosed = true
-	c.connection.Close()
+	c.closeWith(websocket.CloseNormalClosure, "")
+}
+
+// closeWith is close with a specific close code, only the first call decides the code.
+func (c *client) closeWith(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.done)
+	})
+}
+
+// flushAndClose is closeWith, except the writer first sends the frames that
+// are still queued, for as long as deadline allows.
+func (c *client) flushAndClose(code int, reason string, deadline time.Time) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		c.flushDeadline = deadline
+		close(c.done)
+	})
+}
+
+// hold parks live frames until release is called.
+func (c *client) hold() {
+	c.mu.Lock()
+	c.holding = true
+	c.mu.Unlock()
+}
+
+// release queues the parked frames, dropping those skip reports as already
+// delivered, and switches back to live delivery. Like enqueueWait it must
+// only be called from the reader goroutine.
+func (c *client) release(skip func(f frame) bool) {
+	for {
+		c.mu.Lock()
+		held := c.held
+		c.held = nil
+		if len(held) == 0 {
+			c.holding = false
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		for _, f := range held {
+			if !skip(f) {
+				c.enqueueWait(f)
+			}
+		}
+	}
+}
+
+func (c *client) subscribe(conversationID string) {
+	c.mu.Lock()
+	c.subscriptions[conversationID] = struct{}{}
+	c.mu.Unlock()
+}
+
+func (c *client) unsubscribe(conversationID string) {
+	c.mu.Lock()
+	delete(c.subscriptions, conversationID)
+	c.mu.Unlock()
+}
+
+func (c *client) hasSubscriptions() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.subscriptions) > 0
+}
+
+// receives reports whether frames on the conversation's topic reach the
+// client, which is every conversation until the client subscribes to one.
+func (c *client) receives(conversationID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.subscriptions) == 0 {
+		return true
+	}
+	_, ok := c.subscriptions[conversationID]
+	return ok
 }
@@ -0,0 +1,127 @@
+package websocket
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Subprotocols a client can ask for in Sec-WebSocket-Protocol to choose the
+// wire encoding, in the server's order of preference. Clients that ask for
+// none of them get JSON.
+const (
+	SubprotocolMsgpack = "msgpack"
+	SubprotocolJSON    = "json"
+)
+
+var Subprotocols = []string{SubprotocolMsgpack, SubprotocolJSON}
+
+// codec translates between the wire encoding of a connection and JSON, the
+// form frames take inside the server and on the backplane, so a frame is
+// built once and only re-encoded for the connections that need it.
+type codec interface {
+	frameType() int
+	encode(data []byte) ([]byte, error)
+	decode(data []byte) ([]byte, error)
+}
+
+func codecFor(subprotocol string) codec {
+	if subprotocol == SubprotocolMsgpack {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
+
+// encodings caches the wire encodings of a frame delivered to many
+// connections, so it is encoded once per codec rather than once per connection.
+type encodings struct {
+	mu      sync.Mutex
+	byCodec map[codec][]byte
+}
+
+func newEncodings() *encodings {
+	return &encodings{byCodec: make(map[codec][]byte)}
+}
+
+func (e *encodings) encode(c codec, data []byte) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if encoded, ok := e.byCodec[c]; ok {
+		return encoded, nil
+	}
+	encoded, err := c.encode(data)
+	if err != nil {
+		return nil, err
+	}
+	e.byCodec[c] = encoded
+	return encoded, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) frameType() int {
+	return websocket.TextMessage
+}
+
+func (jsonCodec) encode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (jsonCodec) decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// msgpackCodec sends binary MessagePack frames, the payload is a nested map
+// rather than an embedded JSON document.
+type msgpackCodec struct{}
+
+func (msgpackCodec) frameType() int {
+	return websocket.BinaryMessage
+}
+
+func (msgpackCodec) encode(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(withNumbers(v))
+}
+
+func (msgpackCodec) decode(data []byte) ([]byte, error) {
+	var v any
+	if err := msgpack.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// withNumbers turns json.Number into int64 where possible, so sequences and
+// timestamps are sent as MessagePack integers instead of floats.
+func withNumbers(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for key, item := range value {
+			value[key] = withNumbers(item)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = withNumbers(item)
+		}
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+		return value.String()
+	}
+	return v
+}
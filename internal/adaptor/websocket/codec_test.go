@@ -0,0 +1,53 @@
+package websocket_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	tests := []struct {
+		description string
+		subprotocol string
+		frame       string
+	}{
+		{
+			description: "json message frame",
+			subprotocol: websocket.SubprotocolJSON,
+			frame:       `{"event":"message","conversationId":"c-1","seq":42,"payload":{"content":"hi"},"createdAt":1760000000000}`,
+		},
+		{
+			description: "msgpack message frame",
+			subprotocol: websocket.SubprotocolMsgpack,
+			frame:       `{"event":"message","conversationId":"c-1","seq":42,"payload":{"content":"hi","reactions":[{"emoji":"👍","count":2}]},"createdAt":1760000000000}`,
+		},
+		{
+			description: "msgpack frame with floats, nulls and booleans",
+			subprotocol: websocket.SubprotocolMsgpack,
+			frame:       `{"event":"user_status","payload":{"ratio":0.5,"lastSeenAt":null,"online":true,"ids":[]}}`,
+		},
+	}
+
+	for _, test := range tests {
+		encoded, err := websocket.EncodeFrame(test.subprotocol, []byte(test.frame))
+		assert.Nilf(t, err, test.description)
+
+		decoded, err := websocket.DecodeFrame(test.subprotocol, encoded)
+		assert.Nilf(t, err, test.description)
+		assert.JSONEqf(t, test.frame, string(decoded), test.description)
+	}
+}
+
+func TestMsgpackIntegers(t *testing.T) {
+	encoded, err := websocket.EncodeFrame(websocket.SubprotocolMsgpack, []byte(`{"seq":42,"createdAt":1760000000000,"ratio":0.5}`))
+	assert.Nil(t, err)
+
+	var frame map[string]any
+	assert.Nil(t, msgpack.Unmarshal(encoded, &frame))
+	assert.Equal(t, int64(42), frame["seq"])
+	assert.Equal(t, int64(1760000000000), frame["createdAt"])
+	assert.Equal(t, 0.5, frame["ratio"])
+}
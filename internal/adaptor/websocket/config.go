@@ -0,0 +1,70 @@
+package websocket
+
+import "time"
+
+type Config struct {
+	// PingInterval is how often the server pings every connection.
+	PingInterval time.Duration `env:"PING_INTERVAL" envDefault:"30s"`
+	// PongTimeout is how long a connection may stay silent, pongs included,
+	// before it is considered dead and evicted. It must exceed PingInterval.
+	PongTimeout time.Duration `env:"PONG_TIMEOUT" envDefault:"75s"`
+	// AwayAfter is how long a user may show no activity before going away.
+	AwayAfter time.Duration `env:"AWAY_AFTER" envDefault:"5m"`
+	// TypingTimeout ends a typing indicator the client never ended itself.
+	TypingTimeout time.Duration `env:"TYPING_TIMEOUT" envDefault:"6s"`
+	// TypingThrottle is the minimum gap between relayed typing_start events
+	// of a user in a conversation, starts in between only extend the timeout.
+	TypingThrottle time.Duration `env:"TYPING_THROTTLE" envDefault:"2s"`
+	// SendQueueSize is how many frames may wait for a slow client.
+	SendQueueSize int `env:"SEND_QUEUE_SIZE" envDefault:"64"`
+	// SlowConsumerPolicy decides what happens once the queue is full, either
+	// "drop" to discard typing and presence frames or "disconnect".
+	SlowConsumerPolicy string `env:"SLOW_CONSUMER_POLICY" envDefault:"drop"`
+	// ShutdownTimeout bounds how long shutdown waits for queues to flush.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"10s"`
+	// ReconnectJitter spreads the reconnect hints sent on shutdown.
+	ReconnectJitter time.Duration `env:"RECONNECT_JITTER" envDefault:"5s"`
+	// EventRetention is how long conversation events are kept for replay,
+	// a client resuming from before that refetches over REST.
+	EventRetention time.Duration `env:"EVENT_RETENTION" envDefault:"168h"`
+	// RateLimit bounds how many events clients may send.
+	RateLimit RateLimitConfig `envPrefix:"RATE_LIMIT_"`
+}
+
+const (
+	defaultPingInterval    = 30 * time.Second
+	defaultPongTimeout     = 75 * time.Second
+	defaultAwayAfter       = 5 * time.Minute
+	defaultTypingTimeout   = 6 * time.Second
+	defaultSendQueueSize   = 64
+	defaultShutdownTimeout = 10 * time.Second
+	defaultEventRetention  = 7 * 24 * time.Hour
+)
+
+func (c Config) withDefaults() Config {
+	if c.PingInterval <= 0 {
+		c.PingInterval = defaultPingInterval
+	}
+	if c.PongTimeout <= c.PingInterval {
+		c.PongTimeout = c.PingInterval * 5 / 2
+	}
+	if c.AwayAfter <= 0 {
+		c.AwayAfter = defaultAwayAfter
+	}
+	if c.TypingTimeout <= 0 {
+		c.TypingTimeout = defaultTypingTimeout
+	}
+	if c.TypingThrottle < 0 || c.TypingThrottle >= c.TypingTimeout {
+		c.TypingThrottle = c.TypingTimeout / 3
+	}
+	if c.SendQueueSize <= 0 {
+		c.SendQueueSize = defaultSendQueueSize
+	}
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if c.EventRetention <= 0 {
+		c.EventRetention = defaultEventRetention
+	}
+	return c
+}
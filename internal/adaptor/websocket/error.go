@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+// ErrorCode is the machine readable reason carried by error frames.
+type ErrorCode string
+
+const (
+	ErrorCodeInvalidFrame     ErrorCode = "invalid_frame"
+	ErrorCodeInvalidPayload   ErrorCode = "invalid_payload"
+	ErrorCodeUnsupportedFrame ErrorCode = "unsupported_frame"
+	ErrorCodeUnknownEvent     ErrorCode = "unknown_event"
+	ErrorCodeUnknownMethod    ErrorCode = "unknown_method"
+	ErrorCodeAuthFailed       ErrorCode = "auth_failed"
+	ErrorCodeBadRequest       ErrorCode = "bad_request"
+	ErrorCodeUnauthorized     ErrorCode = "unauthorized"
+	ErrorCodeForbidden        ErrorCode = "forbidden"
+	ErrorCodeNotFound         ErrorCode = "not_found"
+	ErrorCodeConflict         ErrorCode = "conflict"
+	ErrorCodeRateLimited      ErrorCode = "rate_limited"
+	ErrorCodeInternal         ErrorCode = "internal_error"
+)
+
+// Close codes 4000-4999 are reserved for applications by RFC 6455.
+const (
+	CloseAuthFailed   = 4001
+	CloseSlowConsumer = 4002
+	CloseRateLimited  = 4003
+	CloseDisconnected = 4004
+)
+
+// ErrorEvent is the payload of an error frame. RequestID echoes the ID of the
+// client frame that caused the error, if it had one.
+type ErrorEvent struct {
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	RequestID string    `json:"requestId,omitempty"`
+}
+
+// frameError is returned by event handlers for failures that are not an
+// apperror.AppError, so they still reach the client with a specific code.
+type frameError struct {
+	code    ErrorCode
+	message string
+	err     error
+}
+
+func (e *frameError) Error() string {
+	if e.err == nil {
+		return e.message
+	}
+	return fmt.Sprintf("%s: %v", e.message, e.err)
+}
+
+func (e *frameError) Unwrap() error {
+	return e.err
+}
+
+func newFrameError(code ErrorCode, message string, err error) error {
+	return &frameError{code: code, message: message, err: err}
+}
+
+var (
+	errConversationIDRequired = errors.New("conversationId is required")
+	errMessageIDRequired      = errors.New("messageId is required")
+)
+
+func invalidPayload(event EventType, err error) error {
+	return newFrameError(ErrorCodeInvalidPayload, fmt.Sprintf("invalid %s payload", event), err)
+}
+
+// toErrorEvent converts err into the client facing error, internal details are never exposed.
+func toErrorEvent(err error, requestID string) ErrorEvent {
+	event := ErrorEvent{
+		Code:      ErrorCodeInternal,
+		Message:   "internal error",
+		RequestID: requestID,
+	}
+
+	var frameErr *frameError
+	var appErr *apperror.AppError
+	switch {
+	case errors.As(err, &frameErr):
+		event.Code = frameErr.code
+		event.Message = frameErr.message
+	case errors.As(err, &appErr):
+		event.Code = errorCodeOf(appErr.Code)
+		event.Message = appErr.Message
+	}
+	return event
+}
+
+func errorCodeOf(status int) ErrorCode {
+	switch status {
+	case fiber.StatusBadRequest, fiber.StatusUnprocessableEntity:
+		return ErrorCodeBadRequest
+	case fiber.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case fiber.StatusForbidden:
+		return ErrorCodeForbidden
+	case fiber.StatusNotFound:
+		return ErrorCodeNotFound
+	case fiber.StatusConflict:
+		return ErrorCodeConflict
+	case fiber.StatusTooManyRequests:
+		return ErrorCodeRateLimited
+	}
+	if status/100 == 4 {
+		return ErrorCodeBadRequest
+	}
+	return ErrorCodeInternal
+}
+
+// closeCodeOf picks the close code sent when the handshake fails with code.
+func closeCodeOf(code ErrorCode) int {
+	switch code {
+	case ErrorCodeInternal:
+		return websocket.CloseInternalServerErr
+	case ErrorCodeUnsupportedFrame:
+		return websocket.CloseUnsupportedData
+	case ErrorCodeInvalidFrame:
+		return websocket.CloseInvalidFramePayloadData
+	}
+	return CloseAuthFailed
+}
@@ -0,0 +1,356 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
+)
+
+// EventContext is what an event handler knows about the frame it handles and
+// the connection that sent it.
+type EventContext struct {
+	server    *messageServer
+	client    *client
+	event     EventType
+	requestID string
+}
+
+func (c *EventContext) Event() EventType {
+	return c.event
+}
+
+// RequestID is the client supplied ID of the frame, it may be empty.
+func (c *EventContext) RequestID() string {
+	return c.requestID
+}
+
+func (c *EventContext) UserID() string {
+	return c.client.userID
+}
+
+func (c *EventContext) ConnectionID() string {
+	return c.client.id
+}
+
+// Send sends a frame to this connection only.
+func (c *EventContext) Send(event EventType, payload any) {
+	c.server.sendToClient(c.client, event, payload)
+}
+
+// Authorize checks that the user may perform action in the conversation.
+func (c *EventContext) Authorize(conversationID string, action conversation.Action) error {
+	return c.server.conversationUC.Authorize(conversationID, c.client.userID, action)
+}
+
+// EventHandler handles one type of client event. An error is reported to the
+// client in an error frame carrying the frame's request ID.
+type EventHandler interface {
+	HandleEvent(ctx *EventContext, payload json.RawMessage) error
+}
+
+type EventHandlerFunc func(ctx *EventContext, payload json.RawMessage) error
+
+func (f EventHandlerFunc) HandleEvent(ctx *EventContext, payload json.RawMessage) error {
+	return f(ctx, payload)
+}
+
+// EventMiddleware wraps a handler, it may stop the event by not calling next.
+type EventMiddleware func(next EventHandler) EventHandler
+
+// Validator is implemented by payloads that check themselves after decoding.
+type Validator interface {
+	Validate() error
+}
+
+// DecodeEvent adapts handler to a raw payload. The payload is decoded into T
+// and validated if T is a Validator, a bad payload never reaches handler.
+//
+// Usage Example:
+//
+//	server.Handle("poll_vote", websocket.DecodeEvent(func(ctx *websocket.EventContext, vote PollVote) error {
+//		return polls.Vote(ctx.UserID(), vote)
+//	}))
+func DecodeEvent[T any](handler func(ctx *EventContext, payload T) error) EventHandler {
+	return EventHandlerFunc(func(ctx *EventContext, raw json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			log.Printf("invalid %s payload: %v", ctx.event, err)
+			return invalidPayload(ctx.event, err)
+		}
+
+		// the pointer's method set covers both value and pointer receivers
+		if validator, ok := any(&payload).(Validator); ok {
+			if err := validator.Validate(); err != nil {
+				return newFrameError(ErrorCodeInvalidPayload, fmt.Sprintf("invalid %s payload: %v", ctx.event, err), err)
+			}
+		}
+
+		return handler(ctx, payload)
+	})
+}
+
+// RequireConversation only lets the event through if the user may perform
+// action in the conversation named by the payload's conversationId, or for a
+// payload that only names a messageId, in the conversation of that message.
+func RequireConversation(action conversation.Action) EventMiddleware {
+	return func(next EventHandler) EventHandler {
+		return EventHandlerFunc(func(ctx *EventContext, payload json.RawMessage) error {
+			var target struct {
+				ConversationID string `json:"conversationId"`
+				MessageID      string `json:"messageId"`
+			}
+			if err := json.Unmarshal(payload, &target); err != nil {
+				return invalidPayload(ctx.event, err)
+			}
+
+			conversationID := target.ConversationID
+			if conversationID == "" && target.MessageID != "" {
+				message, err := ctx.server.messageUC.GetByID(target.MessageID)
+				if err != nil {
+					return err
+				}
+				conversationID = message.ConversationID
+			}
+			if conversationID == "" {
+				return invalidPayload(ctx.event, errConversationIDRequired)
+			}
+
+			if err := ctx.Authorize(conversationID, action); err != nil {
+				return err
+			}
+			return next.HandleEvent(ctx, payload)
+		})
+	}
+}
+
+// EventRegistry lets other packages add socket events, see messageServer.Handle.
+type EventRegistry interface {
+	Handle(event EventType, handler EventHandler, middleware ...EventMiddleware)
+	Use(middleware ...EventMiddleware)
+}
+
+// eventRegistry maps event types to their handler. The middleware of Use is
+// wrapped around every handler when it is registered, composed holds the
+// result so dispatch does no wrapping per frame.
+type eventRegistry struct {
+	mu         sync.RWMutex
+	handlers   map[EventType]EventHandler
+	composed   map[EventType]EventHandler
+	unknown    EventHandler
+	middleware []EventMiddleware
+	stats      map[EventType]*eventStats
+}
+
+func newEventRegistry() *eventRegistry {
+	r := &eventRegistry{
+		handlers: make(map[EventType]EventHandler),
+		composed: make(map[EventType]EventHandler),
+		stats:    make(map[EventType]*eventStats),
+	}
+	r.unknown = unknownEvent
+	return r
+}
+
+var unknownEvent = EventHandlerFunc(func(ctx *EventContext, payload json.RawMessage) error {
+	log.Printf("unhandled WebSocket event: %s", ctx.event)
+	return newFrameError(ErrorCodeUnknownEvent, fmt.Sprintf("unknown event %q", ctx.event), nil)
+})
+
+// compose wraps the global middleware around handler, r.mu must be held.
+func (r *eventRegistry) compose(handler EventHandler) EventHandler {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	return handler
+}
+
+// Handle registers handler for event, replacing any previous handler. The
+// middleware only applies to this event and runs inside the global middleware.
+func (s *messageServer) Handle(event EventType, handler EventHandler, middleware ...EventMiddleware) {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	s.events.mu.Lock()
+	s.events.handlers[event] = handler
+	s.events.composed[event] = s.events.compose(handler)
+	s.events.mu.Unlock()
+}
+
+// Use adds middleware that runs around every event handler, in the order it
+// is added. The handlers registered so far are composed again.
+func (s *messageServer) Use(middleware ...EventMiddleware) {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+
+	s.events.middleware = append(s.events.middleware, middleware...)
+	for event, handler := range s.events.handlers {
+		s.events.composed[event] = s.events.compose(handler)
+	}
+	s.events.unknown = s.events.compose(unknownEvent)
+}
+
+// dispatch runs the handler registered for the frame's event.
+func (s *messageServer) dispatch(client *client, msg WebSocketMessage) error {
+	s.events.mu.RLock()
+	handler, ok := s.events.composed[msg.Event]
+	if !ok {
+		handler = s.events.unknown
+	}
+	s.events.mu.RUnlock()
+
+	return handler.HandleEvent(s.eventContext(client, msg), msg.Payload)
+}
+
+// handleDirect runs the handler registered for the frame's event without the
+// global middleware, for events the server raises on a client's behalf, such
+// as the subscriptions of an event stream.
+func (s *messageServer) handleDirect(client *client, msg WebSocketMessage) error {
+	s.events.mu.RLock()
+	handler, ok := s.events.handlers[msg.Event]
+	if !ok {
+		handler = unknownEvent
+	}
+	s.events.mu.RUnlock()
+
+	return handler.HandleEvent(s.eventContext(client, msg), msg.Payload)
+}
+
+func (s *messageServer) eventContext(client *client, msg WebSocketMessage) *EventContext {
+	return &EventContext{
+		server:    s,
+		client:    client,
+		event:     msg.Event,
+		requestID: msg.ID,
+	}
+}
+
+// registerEventHandlers registers the events the server understands out of the box.
+func (s *messageServer) registerEventHandlers() {
+	s.Use(s.recordEventStats, s.limitEventRate)
+
+	s.Handle(EventTypeMessage, DecodeEvent(func(ctx *EventContext, chatMsg ChatMessage) error {
+		return s.handleEventTypeMessage(ctx.client, chatMsg)
+	}))
+	s.Handle(EventTypeTypingStart, DecodeEvent(func(ctx *EventContext, typing TypingEvent) error {
+		return s.handleEventTypeTyping(ctx.client, typing, true)
+	}), RequireConversation(conversation.ActionWrite))
+	s.Handle(EventTypeTypingEnd, DecodeEvent(func(ctx *EventContext, typing TypingEvent) error {
+		return s.handleEventTypeTyping(ctx.client, typing, false)
+	}), RequireConversation(conversation.ActionWrite))
+	s.Handle(EventTypeReactionAdd, DecodeEvent(func(ctx *EventContext, reaction ReactionEvent) error {
+		return s.handleEventTypeReaction(ctx.UserID(), reaction, true)
+	}), RequireConversation(conversation.ActionWrite))
+	s.Handle(EventTypeReactionRemove, DecodeEvent(func(ctx *EventContext, reaction ReactionEvent) error {
+		return s.handleEventTypeReaction(ctx.UserID(), reaction, false)
+	}), RequireConversation(conversation.ActionWrite))
+	s.Handle(EventTypeReadReceipt, DecodeEvent(func(ctx *EventContext, receipt ReadReceiptEvent) error {
+		return s.handleEventTypeReadReceipt(ctx.UserID(), receipt)
+	}), RequireConversation(conversation.ActionWrite))
+	s.Handle(EventTypeResume, DecodeEvent(func(ctx *EventContext, resume ResumeRequest) error {
+		return s.handleEventTypeResume(ctx.client, resume)
+	}))
+	s.Handle(EventTypePresenceUpdate, DecodeEvent(func(ctx *EventContext, update PresenceUpdate) error {
+		return s.handleEventTypePresence(ctx.UserID(), update)
+	}))
+	s.Handle(EventTypeSubscribe, DecodeEvent(func(ctx *EventContext, subscription SubscriptionEvent) error {
+		return s.handleEventTypeSubscribe(ctx.client, subscription, true)
+	}), RequireConversation(conversation.ActionRead))
+	s.Handle(EventTypeUnsubscribe, DecodeEvent(func(ctx *EventContext, subscription SubscriptionEvent) error {
+		return s.handleEventTypeSubscribe(ctx.client, subscription, false)
+	}))
+	s.Handle(EventTypeRequest, DecodeEvent(func(ctx *EventContext, request RPCRequest) error {
+		return s.handleEventTypeRequest(ctx.client, ctx.requestID, request)
+	}))
+	s.Handle(EventTypeActivity, EventHandlerFunc(func(ctx *EventContext, payload json.RawMessage) error {
+		// nothing to do, every frame already counts as activity
+		return nil
+	}))
+}
+
+// limitEventRate rejects events over the connection's or the user's rate
+// limit, and disconnects a connection that keeps exceeding it.
+func (s *messageServer) limitEventRate(next EventHandler) EventHandler {
+	return EventHandlerFunc(func(ctx *EventContext, payload json.RawMessage) error {
+		allowed, disconnect := s.rateLimiter.allow(ctx.client, ctx.event)
+		if allowed {
+			return next.HandleEvent(ctx, payload)
+		}
+
+		if disconnect {
+			log.Printf("user %s connection %s keeps exceeding rate limits, disconnecting", ctx.client.userID, ctx.client.id)
+			s.sendError(ctx.client, newFrameError(ErrorCodeRateLimited, fmt.Sprintf("too many %s events", ctx.event), nil), ctx.requestID)
+			ctx.client.flushAndClose(CloseRateLimited, "rate limit exceeded", time.Now().Add(writeWait))
+			return nil
+		}
+		return newFrameError(ErrorCodeRateLimited, fmt.Sprintf("too many %s events", ctx.event), nil)
+	})
+}
+
+// EventStats counts the events handled per type since the server started.
+type EventStats struct {
+	Handled int64 `json:"handled"`
+	Failed  int64 `json:"failed"`
+	// TotalDuration is the time spent in the handlers, in microseconds
+	TotalDuration int64 `json:"total_duration_us"`
+}
+
+type eventStats struct {
+	mu sync.Mutex
+	EventStats
+}
+
+// recordEventStats counts every registered event and how long its handler
+// took, events that fail are logged. Unknown events are not counted, so a
+// client cannot grow the counters with made up event types.
+func (s *messageServer) recordEventStats(next EventHandler) EventHandler {
+	return EventHandlerFunc(func(ctx *EventContext, payload json.RawMessage) error {
+		start := time.Now()
+		err := next.HandleEvent(ctx, payload)
+		elapsed := time.Since(start)
+
+		if err != nil {
+			log.Printf("%s from user %s failed after %s: %v", ctx.event, ctx.client.userID, elapsed, err)
+		}
+
+		s.events.mu.Lock()
+		if _, registered := s.events.handlers[ctx.event]; !registered {
+			s.events.mu.Unlock()
+			return err
+		}
+		stats, ok := s.events.stats[ctx.event]
+		if !ok {
+			stats = &eventStats{}
+			s.events.stats[ctx.event] = stats
+		}
+		s.events.mu.Unlock()
+
+		stats.mu.Lock()
+		stats.Handled++
+		if err != nil {
+			stats.Failed++
+		}
+		stats.TotalDuration += elapsed.Microseconds()
+		stats.mu.Unlock()
+
+		return err
+	})
+}
+
+// EventStats reports the event counters by event type.
+func (s *messageServer) EventStats() map[EventType]EventStats {
+	s.events.mu.RLock()
+	defer s.events.mu.RUnlock()
+
+	stats := make(map[EventType]EventStats, len(s.events.stats))
+	for event, counters := range s.events.stats {
+		counters.mu.Lock()
+		stats[event] = counters.EventStats
+		counters.mu.Unlock()
+	}
+	return stats
+}
@@ -0,0 +1,180 @@
+package websocket_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
+)
+
+type vote struct {
+	Option string `json:"option"`
+}
+
+func (v vote) Validate() error {
+	if v.Option == "" {
+		return errors.New("option is required")
+	}
+	return nil
+}
+
+func TestDecodeEvent(t *testing.T) {
+	tests := []struct {
+		description   string
+		payload       string
+		expectedError bool
+		expected      string
+	}{
+		{
+			description: "valid payload",
+			payload:     `{"option":"yes"}`,
+			expected:    "yes",
+		},
+		{
+			description:   "malformed payload",
+			payload:       `{"option":`,
+			expectedError: true,
+		},
+		{
+			description:   "invalid payload",
+			payload:       `{"option":""}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		var received string
+		handler := websocket.DecodeEvent(func(ctx *websocket.EventContext, payload vote) error {
+			received = payload.Option
+			return nil
+		})
+
+		err := handler.HandleEvent(&websocket.EventContext{}, json.RawMessage(test.payload))
+		if test.expectedError {
+			assert.NotNilf(t, err, test.description)
+			assert.Emptyf(t, received, test.description)
+			continue
+		}
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, test.expected, received, test.description)
+	}
+}
+
+func TestEventMiddleware(t *testing.T) {
+	server := websocket.NewTestServer(&fakeConversations{}, nil)
+	client := websocket.NewStreamClient("user-1")
+
+	var calls []string
+	trace := func(name string) websocket.EventMiddleware {
+		return func(next websocket.EventHandler) websocket.EventHandler {
+			return websocket.EventHandlerFunc(func(ctx *websocket.EventContext, payload json.RawMessage) error {
+				calls = append(calls, name)
+				return next.HandleEvent(ctx, payload)
+			})
+		}
+	}
+
+	server.Use(trace("first"))
+	server.Handle("poll_vote", websocket.EventHandlerFunc(func(ctx *websocket.EventContext, payload json.RawMessage) error {
+		calls = append(calls, "handler")
+		return nil
+	}), trace("event"))
+	// global middleware added later still wraps the handlers registered before
+	server.Use(trace("second"))
+
+	assert.Nil(t, server.Dispatch(client, websocket.WebSocketMessage{Event: "poll_vote"}))
+	assert.Equal(t, []string{"first", "second", "event", "handler"}, calls)
+
+	calls = nil
+	assert.NotNil(t, server.Dispatch(client, websocket.WebSocketMessage{Event: "poll_close"}))
+	assert.Equal(t, []string{"first", "second"}, calls, "unknown events pass the global middleware")
+}
+
+func TestRequireConversation(t *testing.T) {
+	tests := []struct {
+		description   string
+		payload       string
+		expectedError bool
+	}{
+		{
+			description: "member",
+			payload:     `{"conversationId":"member"}`,
+		},
+		{
+			description:   "not a member",
+			payload:       `{"conversationId":"other"}`,
+			expectedError: true,
+		},
+		{
+			description:   "malformed payload",
+			payload:       `{"conversationId":`,
+			expectedError: true,
+		},
+		{
+			description:   "no conversation",
+			payload:       `{}`,
+			expectedError: true,
+		},
+	}
+
+	server := websocket.NewTestServer(&fakeConversations{}, nil)
+	client := websocket.NewStreamClient("user-1")
+
+	for _, test := range tests {
+		handled := false
+		server.Handle("poll_vote", websocket.EventHandlerFunc(func(ctx *websocket.EventContext, payload json.RawMessage) error {
+			handled = true
+			return nil
+		}), websocket.RequireConversation(conversation.ActionWrite))
+
+		err := server.Dispatch(client, websocket.WebSocketMessage{Event: "poll_vote", Payload: json.RawMessage(test.payload)})
+		assert.Equalf(t, test.expectedError, err != nil, test.description)
+		assert.Equalf(t, !test.expectedError, handled, test.description)
+	}
+}
+
+func TestBuiltinEventValidation(t *testing.T) {
+	tests := []struct {
+		description string
+		event       websocket.EventType
+		payload     string
+	}{
+		{
+			description: "message without conversation",
+			event:       websocket.EventTypeMessage,
+			payload:     `{"id":"client-1","content":"hello"}`,
+		},
+		{
+			description: "typing without conversation",
+			event:       websocket.EventTypeTypingStart,
+			payload:     `{}`,
+		},
+		{
+			description: "subscribe without conversation",
+			event:       websocket.EventTypeSubscribe,
+			payload:     `{"conversationId":""}`,
+		},
+		{
+			description: "reaction without message",
+			event:       websocket.EventTypeReactionAdd,
+			payload:     `{"emoji":"👍"}`,
+		},
+		{
+			description: "read receipt without message",
+			event:       websocket.EventTypeReadReceipt,
+			payload:     `{"conversationId":"member"}`,
+		},
+	}
+
+	server := websocket.NewTestServer(&fakeConversations{}, nil)
+	client := websocket.NewStreamClient("user-1")
+
+	for _, test := range tests {
+		err := server.Dispatch(client, websocket.WebSocketMessage{Event: test.event, Payload: json.RawMessage(test.payload)})
+		assert.NotNilf(t, err, test.description)
+		assert.Equalf(t, websocket.ErrorCodeInvalidPayload, websocket.ToErrorEvent(err, "").Code, test.description)
+	}
+}
@@ -0,0 +1,104 @@
+package websocket
+
+import (
+	"bufio"
+	"time"
+
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
+	"github.com/yokeTH/chat-app-backend/pkg/backplane"
+	"github.com/yokeTH/chat-app-backend/pkg/ticket"
+)
+
+// NewTypingTracker exposes the typing tracker to the external tests.
+func NewTypingTracker(timeout, throttle time.Duration) *typingTracker {
+	return newTypingTracker(timeout, throttle)
+}
+
+func (t *typingTracker) Start(conversationID, userID, clientID string, expire func()) bool {
+	return t.start(typingKey{conversationID: conversationID, userID: userID}, clientID, expire)
+}
+
+func (t *typingTracker) Stop(conversationID, userID string) bool {
+	return t.stop(typingKey{conversationID: conversationID, userID: userID})
+}
+
+func (t *typingTracker) StopClient(clientID string) int {
+	return len(t.stopClient(clientID))
+}
+
+func (t *typingTracker) Observe(event EventType, typing TypingEvent) {
+	t.observe(event, typing)
+}
+
+func (t *typingTracker) TypistsOf(conversationID string) []string {
+	return t.typistsOf(conversationID)
+}
+
+// EncodeFrame encodes a JSON frame for a connection that chose subprotocol.
+func EncodeFrame(subprotocol string, data []byte) ([]byte, error) {
+	return codecFor(subprotocol).encode(data)
+}
+
+// DecodeFrame decodes a frame from a connection that chose subprotocol into JSON.
+func DecodeFrame(subprotocol string, data []byte) ([]byte, error) {
+	return codecFor(subprotocol).decode(data)
+}
+
+func WriteEvent(w *bufio.Writer, id string, data []byte) error {
+	return writeEvent(w, id, data)
+}
+
+// StreamClient is an event stream client whose frames the tests queue directly.
+type StreamClient struct {
+	client *client
+}
+
+func NewStreamClient(userID string) *StreamClient {
+	client := newStreamClient("stream", newBackpressure(64, SlowConsumerDrop))
+	client.userID = userID
+	return &StreamClient{client: client}
+}
+
+// Next pops the next frame queued for the client.
+func (c *StreamClient) Next() ([]byte, bool) {
+	f, ok := c.client.next()
+	return f.data, ok
+}
+
+func (c *StreamClient) Send(data []byte) {
+	c.client.send(frame{data: data})
+}
+
+func (c *StreamClient) Close() {
+	c.client.close()
+}
+
+// StreamProcess streams to w, resuming from lastEventID as HandleEvents does.
+func (c *StreamClient) StreamProcess(w *bufio.Writer, keepAliveInterval time.Duration, lastEventID string) {
+	c.client.streamProcess(w, keepAliveInterval, parseStreamCursor(lastEventID))
+}
+
+// NewTestServer builds a server on a memory backplane around the conversation
+// use case, the dependencies the tests do not reach are nil.
+func NewTestServer(conversationUC conversation.ConversationUseCase, conversationDto dto.ConversationDto) *messageServer {
+	return NewMessageServer(Config{}, nil, nil, conversationUC, nil, nil, nil, conversationDto, backplane.NewMemory(), nil, nil)
+}
+
+// NewStreamTestServer builds a server that only redeems event stream tickets.
+func NewStreamTestServer(streamTicketer ticket.Ticketer) *messageServer {
+	return NewMessageServer(Config{}, nil, nil, nil, nil, nil, nil, nil, backplane.NewMemory(), nil, streamTicketer)
+}
+
+// Dispatch runs a frame from the client the way the read loop does.
+func (s *messageServer) Dispatch(c *StreamClient, msg WebSocketMessage) error {
+	if c.client.limiters == nil {
+		c.client.limiters = s.rateLimiter.connectionLimiters()
+		c.client.userLimiters = s.rateLimiter.acquire(c.client.userID)
+	}
+	return s.dispatch(c.client, msg)
+}
+
+func ToErrorEvent(err error, requestID string) ErrorEvent {
+	return toErrorEvent(err, requestID)
+}
@@ -1,30 +1,42 @@
 package websocket
 
 import (
+	"context"
 	"log"
 	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+	"github.com/yokeTH/chat-app-backend/pkg/backplane"
 )
 
-func (s *messageServer) handleEventTypeMessage(payload json.RawMessage) error {
-	var chatMsg ChatMessage
-	if err := json.Unmarshal(payload, &chatMsg); err != nil {
-		log.Printf("invalid chat message payload: %v", err)
-		return err
-	}
-
-	log.Printf("received message from %s: %s", chatMsg.SenderID, chatMsg.Content)
+func (s *messageServer) handleEventTypeMessage(client *client, chatMsg ChatMessage) error {
+	// the sender is always the authenticated user, chatMsg.SenderID is not trusted
+	log.Printf("received message from %s: %s", client.userID, chatMsg.Content)
 	content := dto.CreateMessageRequest{
-		ConversationID: chatMsg.ConversationID,
-		Content:        chatMsg.Content,
+		ConversationID:  chatMsg.ConversationID,
+		Content:         chatMsg.Content,
+		ClientMessageID: chatMsg.ID,
 	}
 
-	createdMessage, err := s.messageUC.Create(chatMsg.SenderID, content)
+	createdMessage, created, err := s.messageUC.Send(client.userID, content)
 	if err != nil {
 		log.Printf("failed to create message: %v", err)
-		return err
+		s.sendToClient(client, EventTypeNack, newMessageNack(chatMsg.ID, err))
+		return nil
+	}
+
+	s.sendToClient(client, EventTypeAck, MessageAck{
+		ID:             chatMsg.ID,
+		MessageID:      createdMessage.ID,
+		ConversationID: createdMessage.ConversationID,
+		Seq:            createdMessage.Seq,
+		Timestamp:      createdMessage.CreatedAt.UnixMilli(),
+	})
+
+	// a retried send was already broadcast the first time
+	if !created {
+		return nil
 	}
 
 	createdMessageResponse, err := s.messageDto.ToResponse(createdMessage)
@@ -33,140 +45,184 @@ func (s *messageServer) handleEventTypeMessage(payload json.RawMessage) error {
 		return err
 	}
 
-	payloadResponse, err := json.Marshal(createdMessageResponse)
+	return s.BroadcastMessage(*createdMessageResponse)
+}
+
+// sendToClient sends a frame to a single connection only, not to the user's other sockets.
+func (s *messageServer) sendToClient(client *client, event EventType, payload any) {
+	msg, err := newFrame(event, payload)
 	if err != nil {
 		log.Printf("failed to encode json: %v", err)
-		return err
+		return
+	}
+
+	client.send(frame{data: msg})
+}
+
+// newFrame encodes payload into a frame that is not part of a conversation's sequence.
+func newFrame(event EventType, payload any) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
 	}
 
-	createdMessageJson, err := json.Marshal(WebSocketMessage{
-		Event:     EventTypeMessage,
-		Payload:   payloadResponse,
+	return json.Marshal(WebSocketMessage{
+		Event:     event,
+		Payload:   data,
 		CreatedAt: time.Now().UnixMilli(),
 	})
+}
+
+// sendError reports a failed request to the connection that sent it.
+func (s *messageServer) sendError(client *client, err error, requestID string) {
+	s.sendToClient(client, EventTypeError, toErrorEvent(err, requestID))
+}
+
+// newMessageNack rejects a send, a nack answers the send in place of an error frame.
+func newMessageNack(id string, err error) MessageNack {
+	event := toErrorEvent(err, id)
+	return MessageNack{ID: id, Code: event.Code, Reason: event.Message}
+}
+
+// BroadcastMessage sends a persisted message to the conversation members,
+// stamped with the message's sequence number.
+func (s *messageServer) BroadcastMessage(message dto.MessageResponse) error {
+	payload, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("failed to encode json: %v", err)
 		return err
 	}
 
-	if err := s.BroadcastToMembersInConversation(chatMsg.ConversationID, createdMessageJson); err != nil {
-		return err
-	}
+	return s.publishToConversation(WebSocketMessage{
+		Event:          EventTypeMessage,
+		Payload:        payload,
+		ConversationID: message.ConversationID,
+		Seq:            message.Seq,
+		CreatedAt:      message.CreatedAt.UnixMilli(),
+	})
+}
 
-	return nil
+// broadcastConversationEvent records the event under the conversation's next
+// sequence number, so it can be replayed, and sends it to the members.
+func (s *messageServer) broadcastConversationEvent(conversationID string, event EventType, payload any) error {
+	return s.broadcastRecordedEvent(conversationID, event, payload, nil)
 }
 
-func (s *messageServer) BroadcastToMembersInConversation(conversationID string, msg []byte) error {
-	members, err := s.conversationUC.GetMembers(conversationID)
+// broadcastRecordedEvent is broadcastConversationEvent for events whose
+// payload is too large to keep, recorded is kept for the replay instead
+// unless it is nil.
+func (s *messageServer) broadcastRecordedEvent(conversationID string, event EventType, payload, recorded any) error {
+	frame, err := s.newConversationEventFrame(conversationID, event, payload, recorded)
 	if err != nil {
-		log.Printf("failed to get conversation members : %v", err)
 		return err
 	}
-
-	for _, member := range *members {
-		s.sendMessageToUserID(member.ID, msg)
-	}
-	return nil
+	return s.publishToConversation(*frame)
 }
 
-func (s *messageServer) handleEventTypeTyping(payload json.RawMessage, currentUserID string, isTyping bool) error {
-	var typing TypingEvent
-	if err := json.Unmarshal(payload, &typing); err != nil {
-		log.Printf("invalid typing_start payload: %v", err)
-		return err
+func (s *messageServer) newConversationEventFrame(conversationID string, event EventType, payload, recorded any) (*WebSocketMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("failed to encode json: %v", err)
+		return nil, err
+	}
+	recordedData := data
+	if recorded != nil {
+		if recordedData, err = json.Marshal(recorded); err != nil {
+			log.Printf("failed to encode json: %v", err)
+			return nil, err
+		}
 	}
 
-	members, err := s.conversationUC.GetMembers(typing.ConversationID)
+	conversationEvent, err := s.conversationUC.AppendEvent(conversationID, string(event), recordedData)
 	if err != nil {
-		log.Printf("failed to get conversation members : %v", err)
-		return err
+		log.Printf("failed to record conversation event: %v", err)
+		return nil, err
 	}
 
-	var event EventType
-	if isTyping {
-		event = EventTypeTypingStart
-	} else {
-		event = EventTypeTypingEnd
-	}
-	msg, err := json.Marshal(WebSocketMessage{
-		Event:     event,
-		Payload:   payload,
-		CreatedAt: time.Now().UnixMilli(),
-	})
+	return &WebSocketMessage{
+		Event:          event,
+		Payload:        data,
+		ConversationID: conversationID,
+		Seq:            conversationEvent.Seq,
+		CreatedAt:      conversationEvent.CreatedAt.UnixMilli(),
+	}, nil
+}
+
+func (s *messageServer) publishToConversation(frame WebSocketMessage) error {
+	msg, err := json.Marshal(frame)
 	if err != nil {
 		log.Printf("failed to encode json: %v", err)
 		return err
 	}
 
-	for _, member := range *members {
-		if member.ID != currentUserID {
-			s.sendMessageToUserID(member.ID, msg)
-		}
+	return s.BroadcastToMembersInConversation(frame.ConversationID, msg)
+}
+
+func (s *messageServer) BroadcastToMembersInConversation(conversationID string, msg []byte) error {
+	members, err := s.conversationUC.GetMembers(conversationID)
+	if err != nil {
+		log.Printf("failed to get conversation members : %v", err)
+		return err
 	}
 
-	if isTyping {
-		log.Printf("user %s started typing in conversation %s", typing.UserID, typing.ConversationID)
-	} else {
-		log.Printf("user %s ended typing in conversation %s", typing.UserID, typing.ConversationID)
+	userIDs := make([]string, 0, len(*members))
+	for _, member := range *members {
+		userIDs = append(userIDs, member.ID)
 	}
-	return nil
+	return s.publish(userIDs, msg)
 }
 
-func (s *messageServer) broadcastUserStatus(userID string, isOnline bool) {
-	var status UserStatusType
-	if isOnline {
-		status = UserStatusTypeOnline
-	} else {
-		status = UserStatusTypeOffline
+// broadcastUserStatus sends the presence other users should see for userID.
+func (s *messageServer) broadcastUserStatus(userID string) {
+	user, err := s.userUC.GetByID(userID)
+	if err != nil {
+		log.Printf("failed to get user %s: %v", userID, err)
+		return
 	}
 
-	userStatusString, err := json.Marshal(UserStatus{
+	status := UserStatus{
 		UserID: userID,
-		Status: status,
-	})
-	if err != nil {
-		log.Printf("failed to encode json: %v", err)
-		return
+		Status: UserStatusType(user.Status()),
+	}
+	if lastSeen := user.LastSeen(); lastSeen != nil {
+		status.LastSeenAt = lastSeen.UnixMilli()
 	}
 
-	respMsg, err := json.Marshal(WebSocketMessage{
-		Event:     EventTypeUserStatus,
-		Payload:   userStatusString,
-		CreatedAt: time.Now().UnixMilli(),
-	})
+	respMsg, err := newFrame(EventTypeUserStatus, status)
 	if err != nil {
 		log.Printf("failed to encode json: %v", err)
 		return
 	}
 
-	s.broadcast(respMsg)
+	s.publishToContacts(userID, backplaneKindPresence, respMsg)
 }
 
-func (s *messageServer) broadcast(message []byte) {
-	for _, client := range s.clients {
-		client.mu.Lock()
-		client.message <- message
-		client.mu.Unlock()
-	}
-}
-
-func (s *messageServer) BoardcastConversation(conversation dto.ConversationResponse) {
-	payloadResponse, err := json.Marshal(conversation)
+// publishToContacts sends message to userID's own sockets and to every user
+// sharing a conversation with them, nobody else learns about the user.
+func (s *messageServer) publishToContacts(userID, kind string, message []byte) {
+	contactIDs, err := s.conversationUC.GetContactIDs(userID)
 	if err != nil {
-		log.Printf("failed to encode json: %v", err)
+		log.Printf("failed to get contacts of user %s: %v", userID, err)
 		return
 	}
 
-	wsMsg, err := json.Marshal(WebSocketMessage{
-		Event:     EventTypeConversationUpdate,
-		Payload:   payloadResponse,
-		CreatedAt: time.Now().UnixMilli(),
-	})
-	if err != nil {
-		log.Printf("failed to encode json: %v", err)
-		return
+	if err := s.backplane.Publish(context.Background(), backplane.Message{
+		UserIDs: append(contactIDs, userID),
+		Kind:    kind,
+		Payload: message,
+	}); err != nil {
+		log.Printf("failed to publish to contacts: %v", err)
+	}
+}
+
+// BoardcastConversation sends a conversation update to its members only.
+func (s *messageServer) BoardcastConversation(conversation dto.ConversationResponse) {
+	// members and messages would make every recorded update large, the
+	// replay loads the conversation instead
+	ref := ConversationParams{ConversationID: conversation.ID}
+	if err := s.broadcastRecordedEvent(conversation.ID, EventTypeConversationUpdate, conversation, ref); err != nil {
+		log.Printf("failed to broadcast conversation update: %v", err)
 	}
-	s.broadcast(wsMsg)
 }
 
 func (s *messageServer) BroadcastName(userID, name string) {
@@ -186,5 +242,5 @@ func (s *messageServer) BroadcastName(userID, name string) {
 	if err != nil {
 		return
 	}
-	s.broadcast(msg)
+	s.publishToContacts(userID, "", msg)
 }
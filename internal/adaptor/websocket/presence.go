@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+)
+
+// activityInterval is both how often a connection's activity is persisted
+// and how often idle users are looked for, so away is accurate to about it.
+const activityInterval = 30 * time.Second
+
+// backplaneKindPresence tags user_status frames, a newer one supersedes them
+// so they may be dropped for a slow client.
+const backplaneKindPresence = "presence"
+
+func (s *messageServer) handleEventTypePresence(currentUserID string, update PresenceUpdate) error {
+	if _, err := s.userUC.SetPresence(currentUserID, domain.PresenceStatus(update.Status)); err != nil {
+		return err
+	}
+
+	s.broadcastUserStatus(currentUserID)
+	return nil
+}
+
+// RecordActivity is markActive for the clients that report activity over
+// REST, such as event streams, which cannot send activity frames.
+func (s *messageServer) RecordActivity(userID string) error {
+	back, err := s.userUC.RecordActivity(userID)
+	if err != nil {
+		return err
+	}
+	if back {
+		s.broadcastUserStatus(userID)
+	}
+	return nil
+}
+
+// markActive records that the user did something on client. Last seen is
+// shared by every replica, so a user active anywhere is never marked away.
+func (s *messageServer) markActive(client *client) {
+	now := time.Now()
+	if now.Sub(client.lastActivity) < activityInterval {
+		return
+	}
+	client.lastActivity = now
+
+	back, err := s.userUC.RecordActivity(client.userID)
+	if err != nil {
+		log.Printf("failed to record activity of user %s: %v", client.userID, err)
+		return
+	}
+	if back {
+		s.broadcastUserStatus(client.userID)
+	}
+}
+
+// sweepIdle moves the users connected to this replica to away once they have
+// been inactive for AwayAfter.
+func (s *messageServer) sweepIdle(ctx context.Context) {
+	ticker := time.NewTicker(activityInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			seen := make(map[string]struct{})
+			var userIDs []string
+			for _, client := range s.allClients() {
+				if _, ok := seen[client.userID]; !ok {
+					seen[client.userID] = struct{}{}
+					userIDs = append(userIDs, client.userID)
+				}
+			}
+
+			away, err := s.userUC.MarkIdleAway(userIDs, s.config.AwayAfter)
+			if err != nil {
+				log.Printf("failed to mark idle users away: %v", err)
+				continue
+			}
+			for _, userID := range away {
+				s.broadcastUserStatus(userID)
+			}
+		}
+	}
+}
+
+// reapConnections takes the users of replicas that died without closing
+// their sockets offline, when they have no connection left anywhere.
+func (s *messageServer) reapConnections(ctx context.Context) {
+	ticker := time.NewTicker(activityInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			offline, err := s.backplane.ReapConnections(ctx)
+			if err != nil {
+				log.Printf("failed to reap connections of dead replicas: %v", err)
+			}
+			for _, userID := range offline {
+				if err := s.userUC.SetUserOffline(userID); err != nil {
+					log.Printf("failed to set user %s offline: %v", userID, err)
+					continue
+				}
+				s.broadcastUserStatus(userID)
+			}
+		}
+	}
+}
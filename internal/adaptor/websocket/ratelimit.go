@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// limitOff is the text of a Limit that does not limit anything.
+const limitOff = "off"
+
+// Limit is a token bucket written as "<burst>/<interval>", "10/5s" allows
+// bursts of 10 events and refills 10 tokens every 5 seconds. The zero Limit,
+// written "off", does not limit anything.
+type Limit struct {
+	Burst    int
+	Interval time.Duration
+}
+
+func (l *Limit) UnmarshalText(text []byte) error {
+	if string(text) == limitOff {
+		*l = Limit{}
+		return nil
+	}
+
+	burst, interval, ok := strings.Cut(string(text), "/")
+	if !ok {
+		return fmt.Errorf("invalid limit %q, want <burst>/<interval>", text)
+	}
+
+	n, err := strconv.Atoi(burst)
+	// a burst of 0 would read as no limit at all, that has to be asked for with "off"
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid burst in limit %q, want at least 1 or %q", text, limitOff)
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid interval in limit %q", text)
+	}
+
+	l.Burst = n
+	l.Interval = d
+	return nil
+}
+
+func (l Limit) newLimiter() *rate.Limiter {
+	if l.Burst == 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Every(l.Interval/time.Duration(l.Burst)), l.Burst)
+}
+
+// RateLimitConfig limits the events a client sends, both per connection and
+// per user across all of the user's connections. The user limits are kept by
+// each replica on its own, a user connected to several replicas gets the
+// user limits once per replica.
+type RateLimitConfig struct {
+	ConnectionMessage Limit `env:"CONNECTION_MESSAGE" envDefault:"10/5s"`
+	ConnectionTyping  Limit `env:"CONNECTION_TYPING" envDefault:"10/5s"`
+	ConnectionOther   Limit `env:"CONNECTION_OTHER" envDefault:"30/5s"`
+	UserMessage       Limit `env:"USER_MESSAGE" envDefault:"20/5s"`
+	UserTyping        Limit `env:"USER_TYPING" envDefault:"20/5s"`
+	UserOther         Limit `env:"USER_OTHER" envDefault:"60/5s"`
+	// MaxViolations over-limit events within ViolationWindow get a connection
+	// disconnected, zero never disconnects.
+	MaxViolations   int           `env:"MAX_VIOLATIONS" envDefault:"10"`
+	ViolationWindow time.Duration `env:"VIOLATION_WINDOW" envDefault:"1m"`
+}
+
+type eventClass int
+
+const (
+	eventClassMessage eventClass = iota
+	eventClassTyping
+	eventClassOther
+)
+
+func classOf(event EventType) eventClass {
+	switch event {
+	case EventTypeMessage:
+		return eventClassMessage
+	case EventTypeTypingStart, EventTypeTypingEnd:
+		return eventClassTyping
+	}
+	return eventClassOther
+}
+
+type limiters map[eventClass]*rate.Limiter
+
+type userLimiters struct {
+	limiters    limiters
+	connections int
+}
+
+type rateLimiter struct {
+	config RateLimitConfig
+	mu     sync.Mutex
+	users  map[string]*userLimiters
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		config: config,
+		users:  make(map[string]*userLimiters),
+	}
+}
+
+func (r *rateLimiter) connectionLimiters() limiters {
+	return limiters{
+		eventClassMessage: r.config.ConnectionMessage.newLimiter(),
+		eventClassTyping:  r.config.ConnectionTyping.newLimiter(),
+		eventClassOther:   r.config.ConnectionOther.newLimiter(),
+	}
+}
+
+// acquire returns the limiters shared by the connections of userID, each
+// acquire must be matched by a release.
+func (r *rateLimiter) acquire(userID string) limiters {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		user = &userLimiters{limiters: limiters{
+			eventClassMessage: r.config.UserMessage.newLimiter(),
+			eventClassTyping:  r.config.UserTyping.newLimiter(),
+			eventClassOther:   r.config.UserOther.newLimiter(),
+		}}
+		r.users[userID] = user
+	}
+	user.connections++
+	return user.limiters
+}
+
+func (r *rateLimiter) release(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return
+	}
+	if user.connections--; user.connections <= 0 {
+		delete(r.users, userID)
+	}
+}
+
+// allow reports whether client may send event now. Every refusal counts as a
+// violation, disconnect reports that the client has had too many of them.
+func (r *rateLimiter) allow(client *client, event EventType) (allowed bool, disconnect bool) {
+	class := classOf(event)
+	// the connection bucket is checked first so one noisy connection does not
+	// use up the tokens of the user's other connections
+	if client.limiters[class].Allow() && client.userLimiters[class].Allow() {
+		return true, false
+	}
+
+	now := time.Now()
+	if now.Sub(client.violationsSince) > r.config.ViolationWindow {
+		client.violationsSince = now
+		client.violations = 0
+	}
+	client.violations++
+	return false, r.config.MaxViolations > 0 && client.violations >= r.config.MaxViolations
+}
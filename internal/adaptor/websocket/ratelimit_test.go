@@ -0,0 +1,59 @@
+package websocket_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
+)
+
+func TestLimitUnmarshalText(t *testing.T) {
+	tests := []struct {
+		description   string
+		text          string
+		expectedError bool
+		expected      websocket.Limit
+	}{
+		{
+			description: "burst per interval",
+			text:        "10/5s",
+			expected:    websocket.Limit{Burst: 10, Interval: 5 * time.Second},
+		},
+		{
+			description: "off",
+			text:        "off",
+			expected:    websocket.Limit{},
+		},
+		{
+			description:   "zero burst",
+			text:          "0/1m",
+			expectedError: true,
+		},
+		{
+			description:   "missing interval",
+			text:          "10",
+			expectedError: true,
+		},
+		{
+			description:   "negative burst",
+			text:          "-1/1s",
+			expectedError: true,
+		},
+		{
+			description:   "zero interval",
+			text:          "10/0s",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		var limit websocket.Limit
+		err := limit.UnmarshalText([]byte(test.text))
+		assert.Equalf(t, test.expectedError, err != nil, test.description)
+		if test.expectedError {
+			continue
+		}
+		assert.Equalf(t, test.expected, limit, test.description)
+	}
+}
@@ -0,0 +1,33 @@
+package websocket
+
+import (
+	"log"
+
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+)
+
+func (s *messageServer) handleEventTypeReaction(currentUserID string, reactionEvent ReactionEvent, isAdd bool) error {
+	event := EventTypeReactionRemove
+	if isAdd {
+		event = EventTypeReactionAdd
+	}
+
+	var message *domain.Message
+	var err error
+	if isAdd {
+		message, err = s.reactionUC.AddReaction(currentUserID, reactionEvent.MessageID, reactionEvent.Emoji)
+	} else {
+		message, err = s.reactionUC.RemoveReaction(currentUserID, reactionEvent.MessageID, reactionEvent.Emoji)
+	}
+	if err != nil {
+		log.Printf("failed to update reaction: %v", err)
+		return err
+	}
+
+	return s.BroadcastReactions(event, *s.reactionDto.ToMessageReactionsResponse(message))
+}
+
+func (s *messageServer) BroadcastReactions(event EventType, reactions dto.MessageReactionsResponse) error {
+	return s.broadcastConversationEvent(reactions.ConversationID, event, reactions)
+}
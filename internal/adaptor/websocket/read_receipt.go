@@ -0,0 +1,21 @@
+package websocket
+
+import (
+	"log"
+
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+)
+
+func (s *messageServer) handleEventTypeReadReceipt(currentUserID string, receipt ReadReceiptEvent) error {
+	member, err := s.conversationUC.MarkAsRead(receipt.ConversationID, currentUserID, receipt.MessageID)
+	if err != nil {
+		log.Printf("failed to mark conversation as read: %v", err)
+		return err
+	}
+
+	return s.BroadcastReadReceipt(*s.conversationDto.ToReadReceiptResponse(member))
+}
+
+func (s *messageServer) BroadcastReadReceipt(receipt dto.ReadReceiptResponse) error {
+	return s.broadcastConversationEvent(receipt.ConversationID, EventTypeReadReceipt, receipt)
+}
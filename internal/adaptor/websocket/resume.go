@@ -0,0 +1,194 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// replayLimit caps how many messages and events are replayed per conversation,
+// larger gaps should be refetched over REST.
+const replayLimit = 500
+
+// pruneInterval is how often events older than the replay window are deleted.
+const pruneInterval = time.Hour
+
+// handleEventTypeResume replays everything the client missed since the
+// sequences it reports, live frames arriving meanwhile are held back and
+// delivered afterwards so the client sees every conversation in order.
+func (s *messageServer) handleEventTypeResume(client *client, resume ResumeRequest) error {
+	client.hold()
+
+	complete := ResumeComplete{Conversations: make(map[string]int64, len(resume.Conversations))}
+	for conversationID, lastSeq := range resume.Conversations {
+		frames, truncated, err := s.replayFrames(conversationID, lastSeq, client.userID)
+		if err != nil {
+			log.Printf("failed to replay conversation %s for user %s: %v", conversationID, client.userID, err)
+			continue
+		}
+
+		replayedSeq := lastSeq
+		for _, replayed := range frames {
+			msg, err := json.Marshal(replayed)
+			if err != nil {
+				log.Printf("failed to encode json: %v", err)
+				continue
+			}
+			client.enqueueWait(frame{data: msg})
+			replayedSeq = replayed.Seq
+		}
+
+		// a client that never subscribed gets typing frames of every conversation
+		if !client.hasSubscriptions() {
+			s.sendTypingState(client, conversationID)
+		}
+
+		complete.Conversations[conversationID] = replayedSeq
+		if truncated {
+			complete.Truncated = append(complete.Truncated, conversationID)
+		}
+	}
+
+	completePayload, err := json.Marshal(complete)
+	if err != nil {
+		log.Printf("failed to encode json: %v", err)
+	} else if msg, err := json.Marshal(WebSocketMessage{
+		Event:     EventTypeResumeComplete,
+		Payload:   completePayload,
+		CreatedAt: time.Now().UnixMilli(),
+	}); err != nil {
+		log.Printf("failed to encode json: %v", err)
+	} else {
+		client.enqueueWait(frame{data: msg})
+	}
+
+	client.release(func(f frame) bool {
+		var header WebSocketMessage
+		if err := json.Unmarshal(f.data, &header); err != nil || header.Seq == 0 {
+			return false
+		}
+		replayedSeq, ok := complete.Conversations[header.ConversationID]
+		return ok && header.Seq <= replayedSeq
+	})
+
+	return nil
+}
+
+// replayFrames loads the messages and events of the conversation after seq,
+// ordered by sequence. truncated reports that more remain beyond replayLimit.
+func (s *messageServer) replayFrames(conversationID string, seq int64, userID string) ([]WebSocketMessage, bool, error) {
+	messages, err := s.messageUC.GetAfterSeq(conversationID, userID, seq, replayLimit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	events, complete, err := s.conversationUC.GetEventsAfter(conversationID, userID, seq, replayLimit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	frames := make([]WebSocketMessage, 0, len(*messages)+len(*events))
+	for _, message := range *messages {
+		resp, err := s.messageDto.ToResponse(&message)
+		if err != nil {
+			return nil, false, err
+		}
+		payload, err := json.Marshal(resp)
+		if err != nil {
+			return nil, false, err
+		}
+		frames = append(frames, WebSocketMessage{
+			Event:          EventTypeMessage,
+			Payload:        payload,
+			ConversationID: conversationID,
+			Seq:            message.Seq,
+			CreatedAt:      message.CreatedAt.UnixMilli(),
+		})
+	}
+	var conversationPayload json.RawMessage
+	for _, event := range *events {
+		payload := json.RawMessage(event.Payload)
+		// only the ID of an updated conversation is recorded, it is replayed
+		// as the conversation is now
+		if EventType(event.Event) == EventTypeConversationUpdate {
+			if conversationPayload == nil {
+				if conversationPayload, err = s.currentConversation(conversationID); err != nil {
+					return nil, false, err
+				}
+			}
+			payload = conversationPayload
+		}
+		frames = append(frames, WebSocketMessage{
+			Event:          EventType(event.Event),
+			Payload:        payload,
+			ConversationID: conversationID,
+			Seq:            event.Seq,
+			CreatedAt:      event.CreatedAt.UnixMilli(),
+		})
+	}
+
+	sort.Slice(frames, func(i, j int) bool {
+		return frames[i].Seq < frames[j].Seq
+	})
+
+	// when either source hit the limit, only replay up to the point both are
+	// known to be complete so the client never sees a hole in the sequence
+	truncated := !complete
+	limitSeq := int64(-1)
+	if len(*messages) == replayLimit {
+		limitSeq = (*messages)[len(*messages)-1].Seq
+	}
+	if len(*events) == replayLimit {
+		last := (*events)[len(*events)-1].Seq
+		if limitSeq == -1 || last < limitSeq {
+			limitSeq = last
+		}
+	}
+	if limitSeq != -1 {
+		truncated = true
+		cut := sort.Search(len(frames), func(i int) bool {
+			return frames[i].Seq > limitSeq
+		})
+		frames = frames[:cut]
+	}
+
+	return frames, truncated, nil
+}
+
+func (s *messageServer) currentConversation(conversationID string) (json.RawMessage, error) {
+	conversation, err := s.conversationUC.GetConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.conversationDto.ToResponse(conversation)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resp)
+}
+
+// pruneEvents deletes the conversation events older than EventRetention,
+// clients away for longer refetch their conversations over REST.
+func (s *messageServer) pruneEvents(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.conversationUC.PruneEvents(s.config.EventRetention)
+			if err != nil {
+				log.Printf("failed to prune conversation events: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("pruned %d conversation events", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+)
+
+// RPC methods map onto the REST endpoints of the same name, their responses
+// carry the same body the endpoint would return.
+const (
+	RPCMethodListConversations  = "conversations.list"
+	RPCMethodCreateConversation = "conversations.create"
+	RPCMethodJoinConversation   = "conversations.join"
+	RPCMethodListMessages       = "messages.list"
+)
+
+// handleEventTypeRequest runs a request frame and answers with a response
+// frame carrying the request's ID, or with an error frame if it fails.
+func (s *messageServer) handleEventTypeRequest(client *client, requestID string, request RPCRequest) error {
+	if requestID == "" {
+		return newFrameError(ErrorCodeInvalidPayload, "request frame requires an id", nil)
+	}
+
+	var result any
+	var err error
+	switch request.Method {
+	case RPCMethodListConversations:
+		result, err = s.rpcListConversations(request.Params, client)
+	case RPCMethodCreateConversation:
+		result, err = s.rpcCreateConversation(request.Params, client)
+	case RPCMethodJoinConversation:
+		result, err = s.rpcJoinConversation(request.Params, client)
+	case RPCMethodListMessages:
+		result, err = s.rpcListMessages(request.Params, client)
+	default:
+		return newFrameError(ErrorCodeUnknownMethod, fmt.Sprintf("unknown method %q", request.Method), nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.sendResponse(client, requestID, RPCResponse{Method: request.Method, Result: result})
+	return nil
+}
+
+// sendResponse is sendToClient for the frame answering request requestID.
+func (s *messageServer) sendResponse(client *client, requestID string, response RPCResponse) {
+	payload, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("failed to encode json: %v", err)
+		return
+	}
+
+	msg, err := json.Marshal(WebSocketMessage{
+		ID:        requestID,
+		Event:     EventTypeResponse,
+		Payload:   payload,
+		CreatedAt: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("failed to encode json: %v", err)
+		return
+	}
+
+	client.send(frame{data: msg})
+}
+
+func decodeParams(method string, params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return newFrameError(ErrorCodeInvalidPayload, fmt.Sprintf("invalid %s params", method), err)
+	}
+	return nil
+}
+
+// pagination applies the same defaults and bounds as the REST endpoints.
+func (p PaginationParams) pagination() (int, int) {
+	return dto.PageBounds(p.Page, p.Limit)
+}
+
+func (s *messageServer) rpcListConversations(params json.RawMessage, client *client) (any, error) {
+	var p PaginationParams
+	if err := decodeParams(RPCMethodListConversations, params, &p); err != nil {
+		return nil, err
+	}
+	page, limit := p.pagination()
+
+	conversations, last, total, err := s.conversationUC.GetUserConversations(client.userID, limit, page)
+	if err != nil {
+		return nil, err
+	}
+
+	respData, err := s.conversationDto.ToResponseList(*conversations)
+	if err != nil {
+		return nil, err
+	}
+	return dto.SuccessPagination(*respData, page, last, limit, total), nil
+}
+
+func (s *messageServer) rpcListMessages(params json.RawMessage, client *client) (any, error) {
+	var p ListMessagesParams
+	if err := decodeParams(RPCMethodListMessages, params, &p); err != nil {
+		return nil, err
+	}
+	page, limit := p.pagination()
+
+	messages, last, total, err := s.messageUC.GetByConversationPaginated(p.ConversationID, client.userID, limit, page)
+	if err != nil {
+		return nil, err
+	}
+
+	respData, err := s.messageDto.ToResponseList(*messages)
+	if err != nil {
+		return nil, err
+	}
+	return dto.SuccessPagination(*respData, page, last, limit, total), nil
+}
+
+func (s *messageServer) rpcCreateConversation(params json.RawMessage, client *client) (any, error) {
+	var p dto.CreateConversationRequest
+	if err := decodeParams(RPCMethodCreateConversation, params, &p); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userUC.GetByID(client.userID)
+	if err != nil {
+		return nil, err
+	}
+
+	created, system, err := s.conversationUC.CreateConversation(p.Members, user, p.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	respData, err := s.conversationDto.ToResponse(created)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.broadcastSystemMessage(system); err != nil {
+		return nil, err
+	}
+
+	return dto.Success(respData), nil
+}
+
+func (s *messageServer) rpcJoinConversation(params json.RawMessage, client *client) (any, error) {
+	var p ConversationParams
+	if err := decodeParams(RPCMethodJoinConversation, params, &p); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userUC.GetByID(client.userID)
+	if err != nil {
+		return nil, err
+	}
+
+	joined, system, err := s.conversationUC.JoinConversation(p.ConversationID, user)
+	if err != nil {
+		return nil, err
+	}
+	respData, err := s.conversationDto.ToResponse(joined)
+	if err != nil {
+		return nil, err
+	}
+
+	s.BoardcastConversation(*respData)
+
+	if err := s.broadcastSystemMessage(system); err != nil {
+		return nil, err
+	}
+
+	return dto.Success(*respData), nil
+}
+
+func (s *messageServer) broadcastSystemMessage(system *domain.Message) error {
+	systemResponse, err := s.messageDto.ToResponse(system)
+	if err != nil {
+		log.Printf("failed to transform to dto: %v", err)
+		return err
+	}
+
+	return s.BroadcastMessage(*systemResponse)
+}
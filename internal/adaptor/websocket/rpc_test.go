@@ -0,0 +1,109 @@
+package websocket_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+type fakeConversations struct {
+	conversation.ConversationUseCase
+	userID string
+	limit  int
+	page   int
+}
+
+func (f *fakeConversations) GetUserConversations(userID string, limit, page int) (*[]domain.Conversation, int, int, error) {
+	f.userID, f.limit, f.page = userID, limit, page
+	return &[]domain.Conversation{{ID: "conversation-1", Name: "general"}}, 1, 1, nil
+}
+
+// Authorize lets the user act in "member" conversations only.
+func (f *fakeConversations) Authorize(conversationID, userID string, action conversation.Action) error {
+	if conversationID != "member" {
+		return apperror.ForbiddenError(errors.New("not a member"), "not a member of this conversation")
+	}
+	return nil
+}
+
+func request(t *testing.T, method, params string) websocket.WebSocketMessage {
+	payload, err := json.Marshal(websocket.RPCRequest{Method: method, Params: json.RawMessage(params)})
+	assert.Nil(t, err)
+	return websocket.WebSocketMessage{ID: "request-1", Event: websocket.EventTypeRequest, Payload: payload}
+}
+
+func TestRPCDispatch(t *testing.T) {
+	userDto := dto.NewUserDto()
+	conversationDto := dto.NewConversationDto(userDto, dto.NewMessageDto(dto.NewFileDto(nil), dto.NewReactionDto(userDto), userDto))
+
+	tests := []struct {
+		description   string
+		params        string
+		expectedLimit int
+		expectedPage  int
+	}{
+		{
+			description:   "default pagination",
+			expectedLimit: dto.DefaultPageLimit,
+			expectedPage:  1,
+		},
+		{
+			description:   "limit above the maximum",
+			params:        `{"limit":500,"page":2}`,
+			expectedLimit: dto.MaxPageLimit,
+			expectedPage:  2,
+		},
+	}
+
+	for _, test := range tests {
+		conversations := &fakeConversations{}
+		server := websocket.NewTestServer(conversations, conversationDto)
+		client := websocket.NewStreamClient("user-1")
+
+		err := server.Dispatch(client, request(t, websocket.RPCMethodListConversations, test.params))
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, "user-1", conversations.userID, test.description)
+		assert.Equalf(t, test.expectedLimit, conversations.limit, test.description)
+		assert.Equalf(t, test.expectedPage, conversations.page, test.description)
+
+		data, ok := client.Next()
+		assert.Truef(t, ok, test.description)
+
+		var frame struct {
+			ID      string `json:"id"`
+			Event   string `json:"event"`
+			Payload struct {
+				Method string                                           `json:"method"`
+				Result dto.PaginationResponse[dto.ConversationResponse] `json:"result"`
+			} `json:"payload"`
+		}
+		assert.Nilf(t, json.Unmarshal(data, &frame), test.description)
+		assert.Equalf(t, "request-1", frame.ID, test.description)
+		assert.Equalf(t, string(websocket.EventTypeResponse), frame.Event, test.description)
+		assert.Equalf(t, websocket.RPCMethodListConversations, frame.Payload.Method, test.description)
+		assert.Lenf(t, frame.Payload.Result.Data, 1, test.description)
+		assert.Equalf(t, "conversation-1", frame.Payload.Result.Data[0].ID, test.description)
+	}
+}
+
+func TestRPCUnknownMethod(t *testing.T) {
+	server := websocket.NewTestServer(&fakeConversations{}, nil)
+	client := websocket.NewStreamClient("user-1")
+
+	err := server.Dispatch(client, request(t, "conversations.delete", ""))
+	assert.NotNil(t, err)
+
+	event := websocket.ToErrorEvent(err, "request-1")
+	assert.Equal(t, websocket.ErrorCodeUnknownMethod, event.Code)
+	assert.Equal(t, "request-1", event.RequestID)
+
+	_, ok := client.Next()
+	assert.False(t, ok, "no response frame is sent")
+}
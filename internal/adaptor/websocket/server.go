@@ -2,258 +2,423 @@ package websocket
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
+	"net"
 	"sync"
 	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
-	"github.com/yokeTH/chat-app-backend/internal/domain"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/message"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/reaction"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/user"
+	"github.com/yokeTH/chat-app-backend/pkg/backplane"
+	"github.com/yokeTH/chat-app-backend/pkg/ticket"
 )
 
 type messageServer struct {
-	userUC         user.UserUseCase
-	messageUC      message.MessageUseCase
-	conversationUC conversation.ConversationUseCase
-	messageDto     dto.MessageDto
-	clients        map[string]*client
-	wrmu           sync.RWMutex
+	config          Config
+	userUC          user.UserUseCase
+	messageUC       message.MessageUseCase
+	conversationUC  conversation.ConversationUseCase
+	reactionUC      reaction.ReactionUseCase
+	messageDto      dto.MessageDto
+	reactionDto     dto.ReactionDto
+	conversationDto dto.ConversationDto
+	backplane       backplane.Backplane
+	ticketer        ticket.Ticketer
+	streamTicketer  ticket.Ticketer
+	typing          *typingTracker
+	backpressure    *backpressure
+	rateLimiter     *rateLimiter
+	events          *eventRegistry
+	clients         map[string]*client
+	wrmu            sync.RWMutex
+	// closing is guarded by wrmu, once set no socket is accepted anymore
+	closing bool
+	readers sync.WaitGroup
+	// statusBroadcasts tracks the presence broadcasts of connecting and
+	// disconnecting clients, shutdown waits for them before the backplane
+	// is closed
+	statusBroadcasts sync.WaitGroup
 }
 
 type MessageServer interface {
+	EventRegistry
 	BroadcastName(userID, name string)
 	BroadcastToMembersInConversation(conversationID string, msg []byte) error
+	BroadcastMessage(message dto.MessageResponse) error
 	BoardcastConversation(conversation dto.ConversationResponse)
+	BroadcastReactions(event EventType, reactions dto.MessageReactionsResponse) error
+	BroadcastReadReceipt(receipt dto.ReadReceiptResponse) error
+	QueueStats() QueueStats
+	EventStats() map[EventType]EventStats
+	// Connections is local to this replica, Disconnect, DisconnectUser and
+	// DisconnectSession reach every replica through the backplane.
+	Connections(userID string) []ConnectionInfo
+	Disconnect(connectionID string) error
+	DisconnectUser(userID string) error
+	DisconnectSession(sessionID string) error
+	SendNotice(userID string, notice ServerNotice) error
+	HandleEvents(c *fiber.Ctx) error
+	RecordActivity(userID string) error
 }
 
-func NewMessageServer(userUC user.UserUseCase, messageUC message.MessageUseCase, conversationUC conversation.ConversationUseCase, messageDto dto.MessageDto) *messageServer {
-	return &messageServer{
-		userUC:         userUC,
-		messageUC:      messageUC,
-		conversationUC: conversationUC,
-		messageDto:     messageDto,
-		clients:        make(map[string]*client),
+func NewMessageServer(config Config, userUC user.UserUseCase, messageUC message.MessageUseCase, conversationUC conversation.ConversationUseCase, reactionUC reaction.ReactionUseCase, messageDto dto.MessageDto, reactionDto dto.ReactionDto, conversationDto dto.ConversationDto, backplane backplane.Backplane, ticketer, streamTicketer ticket.Ticketer) *messageServer {
+	config = config.withDefaults()
+	server := &messageServer{
+		config:          config,
+		userUC:          userUC,
+		messageUC:       messageUC,
+		conversationUC:  conversationUC,
+		reactionUC:      reactionUC,
+		messageDto:      messageDto,
+		reactionDto:     reactionDto,
+		conversationDto: conversationDto,
+		backplane:       backplane,
+		ticketer:        ticketer,
+		streamTicketer:  streamTicketer,
+		typing:          newTypingTracker(config.TypingTimeout, config.TypingThrottle),
+		backpressure:    newBackpressure(config.SendQueueSize, config.SlowConsumerPolicy),
+		rateLimiter:     newRateLimiter(config.RateLimit),
+		events:          newEventRegistry(),
+		clients:         make(map[string]*client),
 	}
+	server.registerEventHandlers()
+	return server
 }
 
 func (s *messageServer) Start(ctx context.Context, stop context.CancelFunc) {
-	go s.sendMessageProcess()
+	if err := s.backplane.Subscribe(ctx, s.deliver); err != nil {
+		log.Printf("failed to subscribe to backplane: %v", err)
+		stop()
+		return
+	}
+
+	go s.sweepIdle(ctx)
+	go s.reapConnections(ctx)
+	go s.pruneEvents(ctx)
 
 	<-ctx.Done()
 	log.Println("shutting down message server...")
+	s.shutdown()
 }
 
-func (s *messageServer) receiveMessageProcess(uuid string, client *client) {
-	defer func() {
-		client.isClosed = true
-	}()
-
-	// First message must be auth
-	if err := s.auth(client); err != nil {
+func (s *messageServer) receiveMessageProcess(client *client, ticket string) {
+	// Without a ticket in the URL the first message must be auth, and it must
+	// arrive before the pong timeout
+	s.extendReadDeadline(client)
+	if err := s.auth(client, ticket); err != nil {
 		log.Printf("Authentication failed: %v", err)
-		client.sendError("Authentication failed")
+		s.rejectClient(client, err)
 		return
 	}
 
-	s.addClient(uuid, client)
+	writerDone := make(chan struct{})
+	go func() {
+		client.writeProcess(s.config.PingInterval)
+		close(writerDone)
+	}()
+
+	// a connection that stops answering pings times out on read and is
+	// evicted through the deferred removeClientByID like any other disconnect
+	client.connection.SetPongHandler(func(string) error {
+		s.extendReadDeadline(client)
+		return nil
+	})
+
+	s.addClient(client)
+
+	client.limiters = s.rateLimiter.connectionLimiters()
+	client.userLimiters = s.rateLimiter.acquire(client.userID)
+
+	defer func() {
+		s.rateLimiter.release(client.userID)
+		client.close()
+		s.endTypingOf(client)
+		s.removeClientByID(client.id)
+		<-writerDone
+	}()
 
 	for {
 		messageType, message, err := client.connection.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("user %s missed heartbeat, evicting connection %s", client.userID, client.id)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("read message error: %v\n", err)
 			} else {
 				log.Printf("user %s connection closed: %v", client.userID, err)
 			}
-			client.close()
 			return
 		}
+		s.extendReadDeadline(client)
+		s.markActive(client)
 
-		switch messageType {
-		case websocket.TextMessage:
-			var wsMsg WebSocketMessage
-			if err := json.Unmarshal(message, &wsMsg); err != nil {
-				log.Printf("invalid WebSocket message: %s, error: %v\n", string(message), err)
-				continue
-			}
+		if messageType != client.codec.frameType() {
+			log.Printf("websocket message type %d ignored\n", messageType)
+			s.sendError(client, newFrameError(ErrorCodeUnsupportedFrame, "frame type does not match the negotiated encoding", nil), "")
+			continue
+		}
 
-			switch wsMsg.Event {
-			case EventTypeMessage:
-				if err := s.handleEventTypeMessage(wsMsg.Payload); err != nil {
-					continue
-				}
-			case EventTypeTypingStart:
-				if err := s.handleEventTypeTyping(wsMsg.Payload, client.userID, true); err != nil {
-					continue
-				}
-			case EventTypeTypingEnd:
-				if err := s.handleEventTypeTyping(wsMsg.Payload, client.userID, false); err != nil {
-					continue
-				}
-			default:
-				log.Printf("unhandled WebSocket event: %s", wsMsg.Event)
-			}
+		var wsMsg WebSocketMessage
+		if message, err = client.codec.decode(message); err != nil {
+			log.Printf("undecodable WebSocket message, error: %v\n", err)
+			s.sendError(client, newFrameError(ErrorCodeInvalidFrame, "frame is not a valid event", err), "")
+			continue
+		}
+		if err := json.Unmarshal(message, &wsMsg); err != nil {
+			log.Printf("invalid WebSocket message: %s, error: %v\n", string(message), err)
+			s.sendError(client, newFrameError(ErrorCodeInvalidFrame, "frame is not a valid event", err), "")
+			continue
+		}
 
-		default:
-			log.Printf("websocket message type %d ignored\n", messageType)
+		if err := s.dispatch(client, wsMsg); err != nil {
+			s.sendError(client, err, wsMsg.ID)
+		}
+		// a handler or middleware closed the connection, such as the rate limiter
+		if client.isClosed() {
+			return
 		}
 	}
 }
 
-func (m *messageServer) registerClient(c *websocket.Conn) *sync.WaitGroup {
-	m.wrmu.Lock()
-	defer m.wrmu.Unlock()
-	var wg sync.WaitGroup
-	wg.Add(1)
-	requestid := c.Locals("requestid").(string)
-	client := client{
-		id:         requestid,
-		message:    make(chan []byte, 10),
-		connection: c,
-		wg:         &wg,
-		terminate:  make(chan bool, 1),
+func (s *messageServer) extendReadDeadline(client *client) {
+	_ = client.connection.SetReadDeadline(time.Now().Add(s.config.PongTimeout))
+}
+
+// rejectClient tells a connection that failed the handshake why, then closes it.
+func (s *messageServer) rejectClient(client *client, err error) {
+	event := toErrorEvent(err, "")
+	frame, encodeErr := newFrame(EventTypeError, event)
+	if encodeErr != nil {
+		log.Printf("failed to encode json: %v", encodeErr)
 	}
-	go m.receiveMessageProcess(requestid, &client)
-	return &wg
+	client.reject(frame, closeCodeOf(event.Code), event.Message)
 }
 
+// QueueStats reports how often slow clients lost frames or were disconnected.
+func (s *messageServer) QueueStats() QueueStats {
+	return s.backpressure.stats()
+}
+
+// HandleWebsocket blocks for the lifetime of the connection, the underlying
+// connection is released by fiber as soon as it returns.
 func (m *messageServer) HandleWebsocket(c *websocket.Conn) {
-	m.registerClient(c).Wait()
+	// the ID is generated here, the request ID is chosen by the client and
+	// two connections sharing one would replace each other in clients
+	client := newClient(uuid.NewString(), c, m.backpressure)
+
+	m.wrmu.Lock()
+	if m.closing {
+		m.wrmu.Unlock()
+		hint, _ := m.reconnectHint()
+		client.reject(hint, websocket.CloseGoingAway, shutdownReason)
+		return
+	}
+	m.readers.Add(1)
+	m.wrmu.Unlock()
+	defer m.readers.Done()
+
+	m.receiveMessageProcess(client, c.Query("ticket"))
 }
 
-func (m *messageServer) sendMessageToUserID(id string, message []byte) {
-	for _, client := range m.getClientByUserID(id) {
-		if client != nil {
-			client.mu.Lock()
-			client.message <- message
-			client.mu.Unlock()
+// deliver hands a backplane message to the matching sockets held by this replica.
+func (m *messageServer) deliver(msg backplane.Message) {
+	if msg.Kind == backplaneKindDisconnect {
+		m.disconnect(msg)
+		return
+	}
+	if msg.Kind == backplaneKindTyping {
+		m.observeTyping(msg.Payload)
+	}
+
+	droppable := msg.Kind == backplaneKindTyping || msg.Kind == backplaneKindPresence
+
+	var clients []*client
+	if len(msg.UserIDs) == 0 {
+		clients = m.allClients()
+	} else {
+		for _, userID := range msg.UserIDs {
+			clients = append(clients, m.getClientByUserID(userID)...)
 		}
 	}
+
+	encodings := newEncodings()
+	for _, client := range clients {
+		if msg.Topic != "" && !client.receives(msg.Topic) {
+			continue
+		}
+		client.send(frame{data: msg.Payload, droppable: droppable, encodings: encodings})
+	}
+}
+
+// publish sends message to the sockets of userIDs on every replica.
+func (m *messageServer) publish(userIDs []string, message []byte) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return m.backplane.Publish(context.Background(), backplane.Message{
+		UserIDs: userIDs,
+		Payload: message,
+	})
 }
 
 func (m *messageServer) getClientByUserID(userID string) []*client {
+	m.wrmu.RLock()
+	defer m.wrmu.RUnlock()
+	return m.clientsOfUser(userID)
+}
+
+// clientsOfUser expects the caller to hold wrmu.
+func (m *messageServer) clientsOfUser(userID string) []*client {
 	var clients []*client
 	for _, client := range m.clients {
-		if client != nil && client.userID == userID {
+		if client.userID == userID {
 			clients = append(clients, client)
 		}
 	}
 	return clients
 }
 
-//nolint:unused
+// allClients returns a snapshot so callers can send without holding wrmu.
+func (m *messageServer) allClients() []*client {
+	m.wrmu.RLock()
+	defer m.wrmu.RUnlock()
+	clients := make([]*client, 0, len(m.clients))
+	for _, client := range m.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// removeClientByUserID closes every connection of the user, each reader then
+// removes its own client.
 func (s *messageServer) removeClientByUserID(id string) {
-	s.wrmu.Lock()
-	defer s.wrmu.Unlock()
-	clients := s.getClientByUserID(id)
-	for _, client := range clients {
-		if client != nil {
-			client.wg.Done()
-			delete(s.clients, client.id)
-			_ = s.userUC.SetUserOffline(id)
-		}
+	for _, client := range s.getClientByUserID(id) {
+		log.Printf("disconnecting connection %s of user %s", client.id, client.userID)
+		client.closeWith(CloseDisconnected, disconnectReason)
 	}
 }
 
 func (s *messageServer) removeClientByID(id string) {
 	s.wrmu.Lock()
-	defer s.wrmu.Unlock()
 	client, ok := s.clients[id]
 	if !ok {
+		s.wrmu.Unlock()
 		return
 	}
-	client.wg.Done()
 	delete(s.clients, id)
-	userID := client.userID
-	cnt := 0
-	for _, client := range s.clients {
-		if client.userID == userID {
-			cnt++
-		}
+	s.wrmu.Unlock()
+
+	remaining, err := s.backplane.DecrConnections(context.Background(), client.userID)
+	if err != nil {
+		log.Printf("failed to count connections of user %s: %v", client.userID, err)
+		return
 	}
-	if cnt == 0 {
-		_ = s.userUC.SetUserOffline(userID)
-		go s.broadcastUserStatus(client.userID, false)
+
+	if remaining == 0 {
+		_ = s.userUC.SetUserOffline(client.userID)
+		s.goBroadcastUserStatus(client.userID)
 	}
 }
 
-func (s *messageServer) auth(c *client) error {
+// auth identifies the user of the connection by a ticket from POST
+// /auth/ws-ticket, taken from the query string or else the first frame.
+func (s *messageServer) auth(c *client, ticket string) error {
+	if ticket != "" {
+		return s.authTicket(c, ticket)
+	}
+
 	msgType, data, err := c.connection.ReadMessage()
 	if err != nil {
 		log.Printf("auth read error: %v", err)
 		return err
 	}
 
-	if msgType != websocket.TextMessage {
-		return fmt.Errorf("invalid message type: %d", msgType)
+	if msgType != c.codec.frameType() {
+		return newFrameError(ErrorCodeUnsupportedFrame, "auth frame type does not match the negotiated encoding", fmt.Errorf("invalid message type: %d", msgType))
 	}
 
 	var auth dto.AuthRequest
+	if data, err = c.codec.decode(data); err != nil {
+		log.Printf("auth decode error: %v", err)
+		return newFrameError(ErrorCodeInvalidFrame, "auth frame is not valid", err)
+	}
 	if err := json.Unmarshal(data, &auth); err != nil {
 		log.Printf("auth unmarshal error: %v", err)
-		return err
+		return newFrameError(ErrorCodeInvalidFrame, "auth frame is not valid", err)
 	}
 
-	profile, err := s.validateGoogleToken(auth.Token)
-	if err != nil {
-		return err
-	}
+	return s.authTicket(c, auth.Ticket)
+}
 
-	userData, err := s.userUC.GetGoogleProfile(profile.Sub)
+// authTicket redeems a ticket issued by POST /auth/ws-ticket.
+func (s *messageServer) authTicket(c *client, token string) error {
+	claims, err := s.redeemTicket(s.ticketer, token)
 	if err != nil {
+		if errors.Is(err, errTicketRejected) {
+			return newFrameError(ErrorCodeAuthFailed, "authentication failed", err)
+		}
 		return err
 	}
 
-	if err := s.userUC.SetUserOnline(userData.ID); err != nil {
-		return err
-	}
-
-	c.userID = userData.ID
-	c.profile = *profile
+	c.userID = claims.UserID
+	c.sessionID = claims.SessionID
 
 	return nil
 }
 
-func (s *messageServer) addClient(uuid string, client *client) {
-	go s.broadcastUserStatus(client.userID, true)
-	s.wrmu.Lock()
-	s.clients[uuid] = client
-	s.wrmu.Unlock()
-}
+var errTicketRejected = errors.New("ticket rejected")
 
-func (s *messageServer) validateGoogleToken(token string) (*domain.Profile, error) {
-	req, err := http.NewRequest("GET", "https://www.googleapis.com/oauth2/v3/userinfo", nil)
+// redeemTicket verifies a ticket locally and claims it, so it is single use
+// across every replica. A ticket that is not valid is errTicketRejected.
+func (s *messageServer) redeemTicket(ticketer ticket.Ticketer, token string) (*ticket.Claims, error) {
+	claims, err := ticketer.Verify(token)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, fmt.Errorf("%w: %v", errTicketRejected, err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	claimed, err := s.backplane.Claim(context.Background(), "ticket:"+claims.ID, ticketer.TTL())
 	if err != nil {
-		return nil, fmt.Errorf("performing request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	if !claimed {
+		return nil, fmt.Errorf("%w: ticket already used", errTicketRejected)
 	}
+	return claims, nil
+}
 
-	var profile domain.Profile
-	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+func (s *messageServer) addClient(client *client) {
+	if _, err := s.backplane.IncrConnections(context.Background(), client.userID); err != nil {
+		log.Printf("failed to count connections of user %s: %v", client.userID, err)
 	}
+	s.wrmu.Lock()
+	s.clients[client.id] = client
+	s.wrmu.Unlock()
 
-	if profile.Email == "" {
-		return nil, fmt.Errorf("missing email in profile")
+	// only once the connection is counted and removeClientByID can find it,
+	// otherwise the user stays online when the connection drops before that
+	// or goes offline when their last other connection closes meanwhile
+	if err := s.userUC.SetUserOnline(client.userID); err != nil {
+		log.Printf("failed to set user %s online: %v", client.userID, err)
 	}
+	s.goBroadcastUserStatus(client.userID)
+}
 
-	return &profile, nil
+// goBroadcastUserStatus broadcasts the presence of userID without blocking
+// the caller.
+func (s *messageServer) goBroadcastUserStatus(userID string) {
+	s.statusBroadcasts.Add(1)
+	go func() {
+		defer s.statusBroadcasts.Done()
+		s.broadcastUserStatus(userID)
+	}()
 }
@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+)
+
+const shutdownReason = "server shutting down"
+
+// shutdown stops accepting sockets, flushes what is queued for the connected
+// ones, closes them with a going away frame and a reconnect hint, and takes
+// their users offline. It returns once every reader is gone or the deadline
+// passes, and the resulting presence broadcasts are sent.
+func (s *messageServer) shutdown() {
+	s.wrmu.Lock()
+	s.closing = true
+	s.wrmu.Unlock()
+
+	deadline := time.Now().Add(s.config.ShutdownTimeout)
+	clients := s.allClients()
+	log.Printf("closing %d sockets...", len(clients))
+	for _, client := range clients {
+		if hint, err := s.reconnectHint(); err == nil {
+			client.enqueue(frame{data: hint})
+		}
+		client.flushAndClose(websocket.CloseGoingAway, shutdownReason, deadline)
+	}
+
+	readersDone := make(chan struct{})
+	go func() {
+		s.readers.Wait()
+		close(readersDone)
+	}()
+
+	select {
+	case <-readersDone:
+	case <-time.After(time.Until(deadline)):
+		log.Println("shutdown deadline passed with sockets still open")
+	}
+
+	// readers that did not exit in time are taken offline here instead
+	for _, client := range s.allClients() {
+		s.removeClientByID(client.id)
+	}
+
+	// the offline broadcasts still need the backplane, which is closed as
+	// soon as Start returns
+	s.statusBroadcasts.Wait()
+}
+
+// reconnectHint tells a client when to reconnect, spread over ReconnectJitter
+// so a restart does not get every client back at the same instant.
+func (s *messageServer) reconnectHint() ([]byte, error) {
+	retryAfter := time.Duration(0)
+	if s.config.ReconnectJitter > 0 {
+		retryAfter = rand.N(s.config.ReconnectJitter)
+	}
+	return newFrame(EventTypeReconnect, ReconnectHint{RetryAfter: retryAfter.Milliseconds()})
+}
@@ -0,0 +1,286 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+// HandleEvents streams the same events as the socket as Server-Sent Events,
+// for clients behind proxies that break websocket upgrades. The stream is
+// read-only, actions go through the REST endpoints and activity is reported
+// with POST /users/me/activity.
+//
+// EventSource cannot set headers, so the stream is opened with a single-use
+// ticket from POST /auth/ws-ticket?transport=sse in the ticket query
+// parameter, an access token in the URL would end up in proxy and access
+// logs. A used ticket cannot reconnect the EventSource on its own, the client
+// opens a new one with a fresh ticket and passes the last event ID it saw in
+// last_event_id. The comma separated conversation IDs in subscribe stand in
+// for subscribe frames, and the Last-Event-ID replays what the stream missed.
+func (s *messageServer) HandleEvents(c *fiber.Ctx) error {
+	claims, err := s.redeemTicket(s.streamTicketer, c.Query("ticket"))
+	if err != nil {
+		if errors.Is(err, errTicketRejected) {
+			return apperror.UnauthorizedError(err, "Invalid or expired ticket")
+		}
+		return apperror.InternalServerError(err, "failed to redeem ticket")
+	}
+
+	s.wrmu.Lock()
+	if s.closing {
+		s.wrmu.Unlock()
+		return apperror.New(fiber.StatusServiceUnavailable, shutdownReason, nil)
+	}
+	s.readers.Add(1)
+	s.wrmu.Unlock()
+
+	streaming := false
+	defer func() {
+		if !streaming {
+			s.readers.Done()
+		}
+	}()
+
+	client := newStreamClient(uuid.NewString(), s.backpressure)
+	client.userID = claims.UserID
+	client.sessionID = claims.SessionID
+
+	// like a subscribe frame, a subscription that is not allowed is answered
+	// with an error event instead of failing the stream
+	for _, conversationID := range strings.Split(c.Query("subscribe"), ",") {
+		if conversationID == "" {
+			continue
+		}
+		payload, err := json.Marshal(SubscriptionEvent{ConversationID: conversationID})
+		if err != nil {
+			return err
+		}
+		if err := s.handleDirect(client, WebSocketMessage{Event: EventTypeSubscribe, Payload: payload}); err != nil {
+			s.sendError(client, err, "")
+		}
+	}
+
+	lastEventID := c.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	cursor := parseStreamCursor(lastEventID)
+	var resume *ResumeRequest
+	if len(cursor.seqs) > 0 {
+		resume = &ResumeRequest{Conversations: make(map[string]int64, len(cursor.seqs))}
+		for conversationID, seq := range cursor.seqs {
+			resume.Conversations[conversationID] = seq
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// stops nginx from buffering the stream
+	c.Set("X-Accel-Buffering", "no")
+
+	streaming = true
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer s.readers.Done()
+
+		// live frames are held from the start, so none overtakes the replay
+		if resume != nil {
+			client.hold()
+		}
+		s.addClient(client)
+		defer func() {
+			client.close()
+			s.removeClientByID(client.id)
+		}()
+
+		if resume != nil {
+			// the stream has no reader goroutine, the replay takes its place
+			go func() {
+				if err := s.handleEventTypeResume(client, *resume); err != nil {
+					log.Printf("failed to resume stream of user %s: %v", client.userID, err)
+				}
+			}()
+		}
+
+		client.streamProcess(w, s.config.PingInterval, cursor)
+	})
+
+	return nil
+}
+
+// maxCursorConversations bounds the event ID, it is sent back in a request
+// header. The conversations advanced longest ago are left out first.
+const maxCursorConversations = 50
+
+// streamCursor is the last sequence a stream delivered per conversation. It
+// is written as the ID of sequenced events, "<conversation>:<seq>,...", so a
+// reconnecting EventSource reports it back in Last-Event-ID.
+type streamCursor struct {
+	seqs map[string]int64
+	// order lists the conversations, the one advanced longest ago first
+	order []string
+}
+
+func parseStreamCursor(id string) *streamCursor {
+	cursor := &streamCursor{seqs: make(map[string]int64)}
+	for _, entry := range strings.Split(id, ",") {
+		conversationID, seq, ok := strings.Cut(entry, ":")
+		if !ok || conversationID == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(seq, 10, 64)
+		if err != nil || n < 0 {
+			continue
+		}
+		cursor.advance(conversationID, n)
+	}
+	return cursor
+}
+
+// advance moves the conversation to seq and reports whether it moved forward.
+func (c *streamCursor) advance(conversationID string, seq int64) bool {
+	current, ok := c.seqs[conversationID]
+	if ok && seq <= current {
+		return false
+	}
+	if ok {
+		for i, id := range c.order {
+			if id == conversationID {
+				c.order = append(c.order[:i], c.order[i+1:]...)
+				break
+			}
+		}
+	}
+	c.seqs[conversationID] = seq
+	c.order = append(c.order, conversationID)
+
+	if len(c.order) > maxCursorConversations {
+		delete(c.seqs, c.order[0])
+		c.order = c.order[1:]
+	}
+	return true
+}
+
+func (c *streamCursor) String() string {
+	entries := make([]string, len(c.order))
+	for i, conversationID := range c.order {
+		entries[i] = conversationID + ":" + strconv.FormatInt(c.seqs[conversationID], 10)
+	}
+	return strings.Join(entries, ",")
+}
+
+// eventID returns the ID to write with a frame, sequenced frames that move
+// the cursor get the whole cursor and every other frame none.
+func (c *streamCursor) eventID(data []byte) string {
+	var header struct {
+		ConversationID string `json:"conversationId"`
+		Seq            int64  `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil || header.Seq == 0 {
+		return ""
+	}
+	if !c.advance(header.ConversationID, header.Seq) {
+		return ""
+	}
+	return c.String()
+}
+
+// streamProcess is the event stream's counterpart of writeProcess. It drains
+// the outbound queue into w and sends keep-alive comments until the client is
+// closed or a write fails, which is how a stream notices it was disconnected.
+func (c *client) streamProcess(w *bufio.Writer, keepAliveInterval time.Duration, cursor *streamCursor) {
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	// sending something right away lets proxies pass the headers on
+	if _, err := w.WriteString(": connected\n\n"); err != nil || w.Flush() != nil {
+		c.close()
+		return
+	}
+
+	for {
+		select {
+		case <-c.wake:
+			for {
+				f, ok := c.next()
+				if !ok {
+					break
+				}
+				if err := writeEvent(w, cursor.eventID(f.data), f.data); err != nil {
+					log.Printf("stream write error to user %s: %v", c.userID, err)
+					c.close()
+					return
+				}
+			}
+			if err := w.Flush(); err != nil {
+				log.Printf("stream write error to user %s: %v", c.userID, err)
+				c.close()
+				return
+			}
+
+		case <-keepAlive.C:
+			if _, err := w.WriteString(": keep-alive\n\n"); err != nil || w.Flush() != nil {
+				log.Printf("keep-alive failed to user %s", c.userID)
+				c.close()
+				return
+			}
+
+		case <-c.done:
+			c.flushStream(w, cursor)
+			return
+		}
+	}
+}
+
+// flushStream is flush for an event stream. A stream has no close frame, so
+// a client only learns why it was closed from a queued frame, such as the
+// reconnect hint sent on shutdown.
+func (c *client) flushStream(w *bufio.Writer, cursor *streamCursor) {
+	if c.flushDeadline.IsZero() {
+		return
+	}
+
+	for time.Now().Before(c.flushDeadline) {
+		f, ok := c.next()
+		if !ok {
+			break
+		}
+		if err := writeEvent(w, cursor.eventID(f.data), f.data); err != nil {
+			return
+		}
+	}
+	_ = w.Flush()
+}
+
+// writeEvent writes a frame as one event, with an id field unless id is
+// empty. A data field cannot contain line breaks, so each line of the frame
+// goes in its own data field.
+func writeEvent(w *bufio.Writer, id string, data []byte) error {
+	if id != "" {
+		if _, err := w.WriteString("id: " + id + "\n"); err != nil {
+			return err
+		}
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if _, err := w.WriteString("data: "); err != nil {
+			return err
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return w.WriteByte('\n')
+}
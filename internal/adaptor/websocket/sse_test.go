@@ -0,0 +1,139 @@
+package websocket_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+	"github.com/yokeTH/chat-app-backend/pkg/ticket"
+)
+
+func TestWriteEvent(t *testing.T) {
+	tests := []struct {
+		description string
+		id          string
+		data        string
+		expected    string
+	}{
+		{
+			description: "frame without id",
+			data:        `{"event":"typing_start"}`,
+			expected:    "data: {\"event\":\"typing_start\"}\n\n",
+		},
+		{
+			description: "frame with id",
+			id:          "c-1:4",
+			data:        `{"event":"message","seq":4}`,
+			expected:    "id: c-1:4\ndata: {\"event\":\"message\",\"seq\":4}\n\n",
+		},
+		{
+			description: "line breaks are split into data fields",
+			data:        "{\n\"event\":\"message\"\n}",
+			expected:    "data: {\ndata: \"event\":\"message\"\ndata: }\n\n",
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		assert.Nilf(t, websocket.WriteEvent(w, test.id, []byte(test.data)), test.description)
+		assert.Nilf(t, w.Flush(), test.description)
+		assert.Equalf(t, test.expected, buf.String(), test.description)
+	}
+}
+
+// readEvent reads the next event from a stream, skipping comments.
+func readEvent(t *testing.T, r *bufio.Reader) []string {
+	var fields []string
+	for {
+		line, err := r.ReadString('\n')
+		if !assert.Nil(t, err) {
+			return fields
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, ":") {
+			fields = append(fields, line)
+		}
+	}
+}
+
+func TestStreamProcess(t *testing.T) {
+	reader, writer := io.Pipe()
+	client := websocket.NewStreamClient("user-1")
+
+	done := make(chan struct{})
+	go func() {
+		client.StreamProcess(bufio.NewWriter(writer), time.Hour, "c-2:7")
+		writer.Close()
+		close(done)
+	}()
+
+	r := bufio.NewReader(reader)
+
+	client.Send([]byte(`{"event":"message","conversationId":"c-1","seq":3}`))
+	assert.Equal(t, []string{"id: c-2:7,c-1:3", `data: {"event":"message","conversationId":"c-1","seq":3}`}, readEvent(t, r))
+
+	client.Send([]byte(`{"event":"typing_start","payload":{"conversationId":"c-1"}}`))
+	assert.Equal(t, []string{`data: {"event":"typing_start","payload":{"conversationId":"c-1"}}`}, readEvent(t, r), "unsequenced frames carry no id")
+
+	client.Send([]byte(`{"event":"message","conversationId":"c-2","seq":8}`))
+	assert.Equal(t, []string{"id: c-1:3,c-2:8", `data: {"event":"message","conversationId":"c-2","seq":8}`}, readEvent(t, r))
+
+	client.Send([]byte(`{"event":"message","conversationId":"c-2","seq":8}`))
+	assert.Equal(t, []string{`data: {"event":"message","conversationId":"c-2","seq":8}`}, readEvent(t, r), "a frame that does not advance the cursor carries no id")
+
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream did not stop after close")
+	}
+}
+
+func TestHandleEventsTicket(t *testing.T) {
+	config := ticket.Config{Secret: "secret", TTL: time.Minute, Audience: ticket.AudienceEventStream}
+	streamTicketer, err := ticket.New(config)
+	assert.Nil(t, err)
+	config.Audience = ticket.AudienceWebSocket
+	wsTicketer, err := ticket.New(config)
+	assert.Nil(t, err)
+
+	wsTicket, _, err := wsTicketer.Issue("user-1")
+	assert.Nil(t, err)
+	forged := url.QueryEscape("eyJ1aWQiOiJ1c2VyLTEifQ.invalid")
+
+	tests := []struct {
+		description string
+		query       string
+	}{
+		{description: "missing ticket", query: ""},
+		{description: "invalid signature", query: "?ticket=" + forged},
+		{description: "websocket ticket", query: "?ticket=" + url.QueryEscape(wsTicket)},
+		{description: "access token", query: "?access_token=" + url.QueryEscape(wsTicket)},
+	}
+
+	server := websocket.NewStreamTestServer(streamTicketer)
+	app := fiber.New(fiber.Config{ErrorHandler: apperror.ErrorHandler})
+	app.Get("/events", server.HandleEvents)
+
+	for _, test := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", "/events"+test.query, nil))
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, fiber.StatusUnauthorized, resp.StatusCode, test.description)
+	}
+}
@@ -0,0 +1,17 @@
+package websocket
+
+// handleEventTypeSubscribe starts or stops sending the conversation's
+// view-only events, such as typing indicators, to this connection. A new
+// subscriber is told who is typing right away. Subscribing is registered
+// behind RequireConversation, unsubscribing needs no check.
+func (s *messageServer) handleEventTypeSubscribe(client *client, subscription SubscriptionEvent, isSubscribe bool) error {
+	if !isSubscribe {
+		client.unsubscribe(subscription.ConversationID)
+		return nil
+	}
+
+	client.subscribe(subscription.ConversationID)
+
+	s.sendTypingState(client, subscription.ConversationID)
+	return nil
+}
@@ -0,0 +1,254 @@
+package websocket
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/yokeTH/chat-app-backend/pkg/backplane"
+)
+
+// backplaneKindTyping tags typing frames, every replica reads them to know
+// who is typing where, whichever replica the typist is connected to.
+const backplaneKindTyping = "typing"
+
+type typingKey struct {
+	conversationID string
+	userID         string
+}
+
+// localTypist is a typist connected to this replica, this replica relays
+// their starts and ends the indicator when they go quiet.
+type localTypist struct {
+	clientID  string
+	relayedAt time.Time
+	timer     *time.Timer
+}
+
+type typingTracker struct {
+	mu       sync.Mutex
+	timeout  time.Duration
+	throttle time.Duration
+	local    map[typingKey]*localTypist
+	// typists holds the expiry of every indicator seen on the backplane
+	typists map[string]map[string]time.Time
+}
+
+func newTypingTracker(timeout, throttle time.Duration) *typingTracker {
+	return &typingTracker{
+		timeout:  timeout,
+		throttle: throttle,
+		local:    make(map[typingKey]*localTypist),
+		typists:  make(map[string]map[string]time.Time),
+	}
+}
+
+// start registers a typing_start from a local client and reports whether it
+// should be relayed. expire is called if no start or end follows in time.
+func (t *typingTracker) start(key typingKey, clientID string, expire func()) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	typist, ok := t.local[key]
+	if !ok {
+		typist = &localTypist{}
+		t.local[key] = typist
+	} else {
+		typist.timer.Stop()
+	}
+	typist.clientID = clientID
+
+	var timer *time.Timer
+	timer = time.AfterFunc(t.timeout, func() {
+		t.mu.Lock()
+		current, ok := t.local[key]
+		if !ok || current.timer != timer {
+			t.mu.Unlock()
+			return
+		}
+		delete(t.local, key)
+		t.mu.Unlock()
+		expire()
+	})
+	typist.timer = timer
+
+	if now.Sub(typist.relayedAt) < t.throttle {
+		return false
+	}
+	typist.relayedAt = now
+	return true
+}
+
+// stop forgets a local typist and reports whether they were typing.
+func (t *typingTracker) stop(key typingKey) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	typist, ok := t.local[key]
+	if !ok {
+		return false
+	}
+	typist.timer.Stop()
+	delete(t.local, key)
+	return true
+}
+
+// stopClient forgets every indicator started from clientID and returns them.
+func (t *typingTracker) stopClient(clientID string) []typingKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var keys []typingKey
+	for key, typist := range t.local {
+		if typist.clientID == clientID {
+			typist.timer.Stop()
+			delete(t.local, key)
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// observe records a typing frame published by any replica.
+func (t *typingTracker) observe(event EventType, typing TypingEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	users := t.typists[typing.ConversationID]
+	if event == EventTypeTypingEnd {
+		delete(users, typing.UserID)
+		if len(users) == 0 {
+			delete(t.typists, typing.ConversationID)
+		}
+		return
+	}
+
+	if users == nil {
+		users = make(map[string]time.Time)
+		t.typists[typing.ConversationID] = users
+	}
+	// a replica that dies never sends the end, so every replica expires it too
+	users[typing.UserID] = time.Now().Add(t.timeout)
+}
+
+// typistsOf returns who is currently typing in the conversation.
+func (t *typingTracker) typistsOf(conversationID string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	userIDs := []string{}
+	for userID, expiresAt := range t.typists[conversationID] {
+		if now.After(expiresAt) {
+			delete(t.typists[conversationID], userID)
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+	return userIDs
+}
+
+// handleEventTypeTyping is registered behind RequireConversation, only
+// members who may write get to show an indicator.
+func (s *messageServer) handleEventTypeTyping(client *client, typing TypingEvent, isTyping bool) error {
+	key := typingKey{conversationID: typing.ConversationID, userID: client.userID}
+	if isTyping {
+		relay := s.typing.start(key, client.id, func() {
+			if err := s.publishTyping(key, false); err != nil {
+				log.Printf("failed to publish typing timeout: %v", err)
+			}
+		})
+		if !relay {
+			return nil
+		}
+	} else {
+		s.typing.stop(key)
+	}
+
+	if err := s.publishTyping(key, isTyping); err != nil {
+		log.Printf("failed to publish typing event: %v", err)
+		return err
+	}
+
+	if isTyping {
+		log.Printf("user %s started typing in conversation %s", key.userID, key.conversationID)
+	} else {
+		log.Printf("user %s ended typing in conversation %s", key.userID, key.conversationID)
+	}
+	return nil
+}
+
+// sendTypingState tells the client who is typing in the conversation. It is
+// the only place the snapshot is sent, whenever a client starts receiving the
+// conversation's typing frames, so it never misses an indicator in flight.
+func (s *messageServer) sendTypingState(client *client, conversationID string) {
+	s.sendToClient(client, EventTypeTypingState, TypingState{
+		ConversationID: conversationID,
+		UserIDs:        s.typing.typistsOf(conversationID),
+	})
+}
+
+// endTypingOf ends the indicators a disconnecting client left behind.
+func (s *messageServer) endTypingOf(client *client) {
+	for _, key := range s.typing.stopClient(client.id) {
+		if err := s.publishTyping(key, false); err != nil {
+			log.Printf("failed to publish typing end: %v", err)
+		}
+	}
+}
+
+// publishTyping relays the indicator to the other members, the typist is
+// always the authenticated user and never whoever a payload claims to be.
+func (s *messageServer) publishTyping(key typingKey, isTyping bool) error {
+	event := EventTypeTypingEnd
+	if isTyping {
+		event = EventTypeTypingStart
+	}
+
+	msg, err := newFrame(event, TypingEvent{ConversationID: key.conversationID, UserID: key.userID})
+	if err != nil {
+		log.Printf("failed to encode json: %v", err)
+		return err
+	}
+
+	members, err := s.conversationUC.GetMembers(key.conversationID)
+	if err != nil {
+		log.Printf("failed to get conversation members : %v", err)
+		return err
+	}
+
+	userIDs := make([]string, 0, len(*members))
+	for _, member := range *members {
+		if member.ID != key.userID {
+			userIDs = append(userIDs, member.ID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	return s.backplane.Publish(context.Background(), backplane.Message{
+		UserIDs: userIDs,
+		Topic:   key.conversationID,
+		Kind:    backplaneKindTyping,
+		Payload: msg,
+	})
+}
+
+// observeTyping keeps the typing tracker in sync with typing frames from every replica.
+func (s *messageServer) observeTyping(payload []byte) {
+	var frame WebSocketMessage
+	if err := json.Unmarshal(payload, &frame); err != nil {
+		return
+	}
+	var typing TypingEvent
+	if err := json.Unmarshal(frame.Payload, &typing); err != nil {
+		return
+	}
+	s.typing.observe(frame.Event, typing)
+}
@@ -0,0 +1,77 @@
+package websocket_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
+)
+
+func TestTypingStartThrottle(t *testing.T) {
+	tracker := websocket.NewTypingTracker(time.Minute, 50*time.Millisecond)
+	defer tracker.StopClient("client-1")
+
+	assert.True(t, tracker.Start("conversation-1", "user-1", "client-1", func() {}), "first start is relayed")
+	assert.False(t, tracker.Start("conversation-1", "user-1", "client-1", func() {}), "start within the throttle is not relayed")
+	assert.True(t, tracker.Start("conversation-2", "user-1", "client-1", func() {}), "another conversation is throttled apart")
+
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, tracker.Start("conversation-1", "user-1", "client-1", func() {}), "start after the throttle is relayed")
+}
+
+func TestTypingExpiry(t *testing.T) {
+	tracker := websocket.NewTypingTracker(100*time.Millisecond, 0)
+
+	var expired atomic.Int32
+	expire := func() { expired.Add(1) }
+
+	tracker.Start("conversation-1", "user-1", "client-1", expire)
+	time.Sleep(50 * time.Millisecond)
+	// a new start pushes the expiry back
+	tracker.Start("conversation-1", "user-1", "client-1", expire)
+	time.Sleep(70 * time.Millisecond)
+	assert.Equal(t, int32(0), expired.Load(), "restarted indicator has not expired")
+
+	assert.Eventually(t, func() bool { return expired.Load() == 1 }, time.Second, 5*time.Millisecond)
+	assert.False(t, tracker.Stop("conversation-1", "user-1"), "expired indicator is forgotten")
+
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, int32(1), expired.Load(), "expire is called once")
+}
+
+func TestTypingStop(t *testing.T) {
+	tracker := websocket.NewTypingTracker(20*time.Millisecond, 0)
+
+	var expired atomic.Int32
+	expire := func() { expired.Add(1) }
+
+	tracker.Start("conversation-1", "user-1", "client-1", expire)
+	tracker.Start("conversation-2", "user-1", "client-1", expire)
+	tracker.Start("conversation-1", "user-2", "client-2", expire)
+
+	assert.True(t, tracker.Stop("conversation-1", "user-2"))
+	assert.False(t, tracker.Stop("conversation-1", "user-2"), "stopped indicator is forgotten")
+	assert.Equal(t, 2, tracker.StopClient("client-1"))
+	assert.Equal(t, 0, tracker.StopClient("client-1"))
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), expired.Load(), "stopped indicators do not expire")
+}
+
+func TestTypingObserve(t *testing.T) {
+	tracker := websocket.NewTypingTracker(30*time.Millisecond, 0)
+
+	tracker.Observe(websocket.EventTypeTypingStart, websocket.TypingEvent{ConversationID: "conversation-1", UserID: "user-2"})
+	tracker.Observe(websocket.EventTypeTypingStart, websocket.TypingEvent{ConversationID: "conversation-1", UserID: "user-1"})
+	assert.Equal(t, []string{"user-1", "user-2"}, tracker.TypistsOf("conversation-1"))
+	assert.Equal(t, []string{}, tracker.TypistsOf("conversation-2"))
+
+	tracker.Observe(websocket.EventTypeTypingEnd, websocket.TypingEvent{ConversationID: "conversation-1", UserID: "user-2"})
+	assert.Equal(t, []string{"user-1"}, tracker.TypistsOf("conversation-1"))
+
+	// an end lost with its replica still expires
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, []string{}, tracker.TypistsOf("conversation-1"))
+}
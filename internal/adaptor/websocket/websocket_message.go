@@ -13,14 +13,36 @@ const (
 	EventTypeReadReceipt        EventType = "read_receipt"
 	EventTypeTypingEnd          EventType = "typing_end"
 	EventTypeTypingStart        EventType = "typing_start"
+	EventTypeTypingState        EventType = "typing_state"
 	EventTypeUserStatus         EventType = "user_status"
 	EventTypeConversationUpdate EventType = "conversation_update"
+	EventTypeResume             EventType = "resume"
+	EventTypeResumeComplete     EventType = "resume_complete"
+	EventTypeAck                EventType = "ack"
+	EventTypeNack               EventType = "nack"
+	EventTypeError              EventType = "error"
+	EventTypePresenceUpdate     EventType = "presence_update"
+	EventTypeActivity           EventType = "activity"
+	EventTypeSubscribe          EventType = "subscribe"
+	EventTypeUnsubscribe        EventType = "unsubscribe"
+	EventTypeReconnect          EventType = "reconnect"
+	EventTypeRequest            EventType = "request"
+	EventTypeResponse           EventType = "response"
+	EventTypeNotice             EventType = "notice"
 )
 
 type WebSocketMessage struct {
-	Event     EventType       `json:"event"`
-	Payload   json.RawMessage `json:"payload"`
-	CreatedAt int64           `json:"created_at"`
+	// ID is an optional client supplied request ID, it is echoed back in the
+	// error frame if the request fails. Request frames require it, and their
+	// response frame carries it as well.
+	ID      string          `json:"id,omitempty"`
+	Event   EventType       `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+	// ConversationID and Seq are only set on events that belong to a conversation
+	// and are persisted, Seq increases monotonically per conversation.
+	ConversationID string `json:"conversationId,omitempty"`
+	Seq            int64  `json:"seq,omitempty"`
+	CreatedAt      int64  `json:"created_at"`
 }
 
 type ChatMessage struct {
@@ -33,6 +55,31 @@ type ChatMessage struct {
 	MessageType    string       `json:"type"`
 }
 
+func (m ChatMessage) Validate() error {
+	if m.ConversationID == "" {
+		return errConversationIDRequired
+	}
+	return nil
+}
+
+// MessageAck confirms to the sender that the message identified by its
+// client supplied ID has been persisted.
+type MessageAck struct {
+	ID             string `json:"id"`
+	MessageID      string `json:"messageId"`
+	ConversationID string `json:"conversationId"`
+	Seq            int64  `json:"seq"`
+	Timestamp      int64  `json:"created_at"`
+}
+
+// MessageNack tells the sender that the message identified by its client
+// supplied ID was rejected, it is safe to retry with the same ID.
+type MessageNack struct {
+	ID     string    `json:"id"`
+	Code   ErrorCode `json:"code"`
+	Reason string    `json:"reason"`
+}
+
 type Attachment struct {
 	ID       string `json:"id"`
 	Type     string `json:"type"`
@@ -47,15 +94,133 @@ type TypingEvent struct {
 	UserID         string `json:"userId"`
 }
 
+func (e TypingEvent) Validate() error {
+	if e.ConversationID == "" {
+		return errConversationIDRequired
+	}
+	return nil
+}
+
+// TypingState lists who is typing in a conversation, it is sent when a
+// client starts receiving the conversation's typing frames.
+type TypingState struct {
+	ConversationID string   `json:"conversationId"`
+	UserIDs        []string `json:"userIds"`
+}
+
+// SubscriptionEvent names the conversation a client starts or stops viewing.
+// A client that never subscribed receives the typing indicators of all its
+// conversations, once it subscribes only viewers receive them.
+type SubscriptionEvent struct {
+	ConversationID string `json:"conversationId"`
+}
+
+func (e SubscriptionEvent) Validate() error {
+	if e.ConversationID == "" {
+		return errConversationIDRequired
+	}
+	return nil
+}
+
+type ReactionEvent struct {
+	MessageID string `json:"messageId"`
+	Emoji     string `json:"emoji"`
+}
+
+func (e ReactionEvent) Validate() error {
+	if e.MessageID == "" {
+		return errMessageIDRequired
+	}
+	return nil
+}
+
+type ReadReceiptEvent struct {
+	ConversationID string `json:"conversationId"`
+	MessageID      string `json:"messageId"`
+}
+
+func (e ReadReceiptEvent) Validate() error {
+	if e.ConversationID == "" {
+		return errConversationIDRequired
+	}
+	if e.MessageID == "" {
+		return errMessageIDRequired
+	}
+	return nil
+}
+
+// ResumeRequest carries the last sequence the client has seen per conversation.
+type ResumeRequest struct {
+	Conversations map[string]int64 `json:"conversations"`
+}
+
+// ResumeComplete marks the end of a replay. Conversations holds the last
+// replayed sequence per conversation, Truncated lists conversations whose gap
+// was too large to replay, or older than the replay window, and must be
+// refetched over REST.
+type ResumeComplete struct {
+	Conversations map[string]int64 `json:"conversations"`
+	Truncated     []string         `json:"truncated,omitempty"`
+}
+
+// ReconnectHint is sent right before the server closes the socket to go
+// away, the client should reconnect after RetryAfter milliseconds.
+type ReconnectHint struct {
+	RetryAfter int64 `json:"retryAfterMs"`
+}
+
 type UserStatusType string
 
 const (
-	UserStatusTypeOnline  UserStatusType = "online"
-	UserStatusTypeOffline UserStatusType = "offline"
+	UserStatusTypeOnline       UserStatusType = "online"
+	UserStatusTypeAway         UserStatusType = "away"
+	UserStatusTypeDoNotDisturb UserStatusType = "dnd"
+	UserStatusTypeOffline      UserStatusType = "offline"
 )
 
 type UserStatus struct {
-	UserID string         `json:"userId,omitempty"`
-	Status UserStatusType `json:"status"`
-	Name   string         `json:"name,omitempty"`
+	UserID     string         `json:"userId,omitempty"`
+	Status     UserStatusType `json:"status"`
+	Name       string         `json:"name,omitempty"`
+	LastSeenAt int64          `json:"lastSeenAt,omitempty"`
+}
+
+// PresenceUpdate sets the presence the user chose: online, away, dnd or invisible.
+type PresenceUpdate struct {
+	Status string `json:"status"`
+}
+
+// RPCRequest is the payload of a request frame, the frame's ID correlates it
+// with its response.
+type RPCRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// RPCResponse is the payload of a response frame, Result is the body the
+// equivalent REST endpoint responds with.
+type RPCResponse struct {
+	Method string `json:"method"`
+	Result any    `json:"result"`
+}
+
+type PaginationParams struct {
+	Limit int `json:"limit,omitempty"`
+	Page  int `json:"page,omitempty"`
+}
+
+type ConversationParams struct {
+	ConversationID string `json:"conversationId"`
+}
+
+type ListMessagesParams struct {
+	ConversationID string `json:"conversationId"`
+	PaginationParams
+}
+
+// ServerNotice is a message from the operators to a user, such as a warning
+// before their session is disconnected.
+type ServerNotice struct {
+	Message string `json:"message"`
+	Level   string `json:"level,omitempty"`
 }
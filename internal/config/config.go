@@ -4,15 +4,31 @@ import (
 	"github.com/caarlos0/env/v11"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/joho/godotenv"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/google"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/middleware"
+	wsAdaptor "github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
 	"github.com/yokeTH/chat-app-backend/internal/server"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/account"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/session"
+	"github.com/yokeTH/chat-app-backend/pkg/backplane"
 	"github.com/yokeTH/chat-app-backend/pkg/db"
+	"github.com/yokeTH/chat-app-backend/pkg/mailer"
 	"github.com/yokeTH/chat-app-backend/pkg/storage"
+	"github.com/yokeTH/chat-app-backend/pkg/ticket"
 )
 
 type config struct {
-	Server       server.Config  `envPrefix:"SERVER_"`
-	PSQL         db.DBConfig    `envPrefix:"POSTGRES_"`
-	PublicBucket storage.Config `envPrefix:"PUBLIC_"`
+	Server       server.Config              `envPrefix:"SERVER_"`
+	PSQL         db.DBConfig                `envPrefix:"POSTGRES_"`
+	PublicBucket storage.Config             `envPrefix:"PUBLIC_"`
+	Backplane    backplane.Config           `envPrefix:"BACKPLANE_"`
+	WebSocket    wsAdaptor.Config           `envPrefix:"WS_"`
+	Ticket       ticket.Config              `envPrefix:"WS_TICKET_"`
+	Google       google.Config              `envPrefix:"GOOGLE_"`
+	Session      session.Config             `envPrefix:"SESSION_"`
+	Password     account.Config             `envPrefix:"PASSWORD_"`
+	Mailer       mailer.Config              `envPrefix:"MAILER_"`
+	AuthLimit    middleware.RateLimitConfig `envPrefix:"AUTH_RATE_LIMIT_"`
 }
 
 func Load() *config {
@@ -15,9 +15,32 @@ type Conversation struct {
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime"`
 
+	// LastSeq is the last sequence number handed out to a message or event in this conversation
+	LastSeq int64 `gorm:"not null;default:0"`
+	// PrunedSeq is the last sequence number of the events removed by
+	// retention, a replay from before it cannot be complete
+	PrunedSeq int64 `gorm:"not null;default:0"`
+
 	// Relationships
-	Members  []User    `gorm:"many2many:conversation_members;"`
-	Messages []Message `gorm:"foreignKey:ConversationID"`
+	Members     []User               `gorm:"many2many:conversation_members;"`
+	Messages    []Message            `gorm:"foreignKey:ConversationID"`
+	Memberships []ConversationMember `gorm:"foreignKey:ConversationID"`
+
+	// UnreadCount is computed per requesting user and never persisted
+	UnreadCount int `gorm:"-"`
+}
+
+// ConversationMember is the join table behind Conversation.Members,
+// extended with how far the member has read.
+type ConversationMember struct {
+	ConversationID    string     `gorm:"primaryKey;type:varchar(36)"`
+	UserID            string     `gorm:"primaryKey;type:varchar(36)"`
+	LastReadMessageID *string    `gorm:"size:36;default:null"`
+	LastReadAt        *time.Time `gorm:"default:null"`
+}
+
+func (ConversationMember) TableName() string {
+	return "conversation_members"
 }
 
 func (c *Conversation) BeforeCreate(tx *gorm.DB) error {
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ConversationEvent records a non-message event broadcast to a conversation
+// (reactions, read receipts, conversation updates) so it can be replayed to
+// clients that reconnect. It shares the conversation's sequence with messages.
+type ConversationEvent struct {
+	ID             string    `gorm:"primaryKey;type:varchar(36)"`
+	ConversationID string    `gorm:"size:36;not null;index:idx_event_conversation_seq,priority:1"`
+	Seq            int64     `gorm:"not null;index:idx_event_conversation_seq,priority:2"`
+	Event          string    `gorm:"size:50;not null"`
+	Payload        string    `gorm:"type:text"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+
+	// Relationships
+	Conversation Conversation `gorm:"foreignKey:ConversationID"`
+}
+
+func (e *ConversationEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
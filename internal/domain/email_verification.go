@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EmailVerification is a request to prove that a user owns their email. The
+// token mailed to the user is only stored hashed, and works once until
+// ExpiresAt. PasswordHash is set when someone registers the email of a Google
+// account, the password is only added to the account once the link is followed.
+type EmailVerification struct {
+	ID           string    `gorm:"primaryKey;type:varchar(36)"`
+	UserID       string    `gorm:"size:36;not null;index"`
+	TokenHash    string    `gorm:"size:64;not null;uniqueIndex"`
+	PasswordHash string    `gorm:"size:255"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	UsedAt       *time.Time
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID"`
+}
+
+func (e *EmailVerification) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -15,14 +15,17 @@ const (
 )
 
 type Message struct {
-	ID             string      `gorm:"primaryKey;type:varchar(36)"`
-	ConversationID string      `gorm:"size:36;not null;index"`
-	SenderID       string      `gorm:"size:36;index;default:null"`
-	Content        string      `gorm:"type:text"`
-	CreatedAt      time.Time   `gorm:"autoCreateTime;index"`
-	UpdatedAt      time.Time   `gorm:"autoUpdateTime"`
-	IsDeleted      bool        `gorm:"default:false"`
-	MessageType    MessageType `gorm:"default:TEXT"`
+	ID             string `gorm:"primaryKey;type:varchar(36)"`
+	ConversationID string `gorm:"size:36;not null;index;index:idx_message_conversation_seq,priority:1"`
+	Seq            int64  `gorm:"not null;default:0;index:idx_message_conversation_seq,priority:2"`
+	SenderID       string `gorm:"size:36;index;default:null;uniqueIndex:idx_message_client_id,priority:1"`
+	// ClientMessageID is the sender supplied idempotency key, unique per sender
+	ClientMessageID *string     `gorm:"size:64;uniqueIndex:idx_message_client_id,priority:2"`
+	Content         string      `gorm:"type:text"`
+	CreatedAt       time.Time   `gorm:"autoCreateTime;index"`
+	UpdatedAt       time.Time   `gorm:"autoUpdateTime"`
+	IsDeleted       bool        `gorm:"default:false"`
+	MessageType     MessageType `gorm:"default:TEXT"`
 
 	// Relationships
 	Conversation Conversation `gorm:"foreignKey:ConversationID"`
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is a login of a user on one device, it lives as long as its refresh
+// token keeps being used. Refresh tokens are only stored hashed, and every
+// refresh replaces the token. PreviousTokenHash keeps the replaced one so a
+// refresh token that is used twice can be detected.
+type Session struct {
+	ID                string    `gorm:"primaryKey;type:varchar(36)"`
+	UserID            string    `gorm:"size:36;not null;index"`
+	TokenHash         string    `gorm:"size:64;not null;uniqueIndex"`
+	PreviousTokenHash string    `gorm:"size:64;index"`
+	ExpiresAt         time.Time `gorm:"not null"`
+	RevokedAt         *time.Time
+	CreatedAt         time.Time `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime"`
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID"`
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// Active reports whether the session can still be refreshed.
+func (s *Session) Active() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// SessionTokens are handed to the client when a session starts or is refreshed.
+type SessionTokens struct {
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+}
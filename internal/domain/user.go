@@ -17,8 +17,21 @@ type User struct {
 	CreatedAt    time.Time `gorm:"autoCreateTime"`
 	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
 
+	// Presence is the state chosen while connected, Status derives what other users see.
+	Presence   PresenceStatus `gorm:"size:20;not null;default:'online'"`
+	LastSeenAt *time.Time
+
+	// Role is never set through the API, an operator grants admin in the database.
+	Role Role `gorm:"size:20;not null;default:'user'"`
+
+	// SessionEpoch moves on when every session of the user is revoked, access
+	// tokens issued under an older epoch stop working.
+	SessionEpoch int64 `gorm:"not null;default:0"`
+
 	Provider   string `gorm:"size:50;uniqueIndex:composite_provider"`
 	ProviderID string `gorm:"size:100;uniqueIndex:composite_provider"`
+	// EmailVerifiedAt is when the user followed a verification link, see EmailVerified.
+	EmailVerifiedAt *time.Time
 
 	// Relationships
 	Conversations []Conversation `gorm:"many2many:conversation_members;"`
@@ -26,6 +39,61 @@ type User struct {
 	Reactions     []Reaction     `gorm:"foreignKey:UserID"`
 }
 
+// ProviderLocal users sign in with an email and a password, their ProviderID
+// is their email. ProviderGoogle users sign in with Google, their ProviderID
+// is their Google subject.
+const (
+	ProviderLocal  = "LOCAL"
+	ProviderGoogle = "GOOGLE"
+)
+
+type PresenceStatus string
+
+const (
+	PresenceOnline       PresenceStatus = "online"
+	PresenceAway         PresenceStatus = "away"
+	PresenceDoNotDisturb PresenceStatus = "dnd"
+	PresenceInvisible    PresenceStatus = "invisible"
+	PresenceOffline      PresenceStatus = "offline"
+)
+
+// Status is the presence other users see, invisible users appear offline.
+func (u *User) Status() PresenceStatus {
+	if !u.IsOnline || u.Presence == PresenceInvisible {
+		return PresenceOffline
+	}
+	if u.Presence == "" {
+		return PresenceOnline
+	}
+	return u.Presence
+}
+
+// LastSeen is the last seen time other users see, it is hidden while the
+// user is invisible so their activity cannot be followed.
+func (u *User) LastSeen() *time.Time {
+	if u.Presence == PresenceInvisible {
+		return nil
+	}
+	return u.LastSeenAt
+}
+
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// EmailVerified reports whether the user proved they own Email. Google only
+// signs in users whose email it verified.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil || u.Provider == ProviderGoogle
+}
+
 type Profile struct {
 	Email         string `json:"email"`
 	EmailVerified bool   `json:"email_verified"`
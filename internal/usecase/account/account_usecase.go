@@ -0,0 +1,375 @@
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+	"github.com/yokeTH/chat-app-backend/pkg/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything after the 72nd byte
+	maxPasswordLength = 72
+)
+
+type Config struct {
+	HashCost int           `env:"HASH_COST" envDefault:"12"`
+	ResetTTL time.Duration `env:"RESET_TTL" envDefault:"1h"`
+	// ResetURL is the page of the client that takes the reset token, the
+	// token is appended as the token query parameter.
+	ResetURL  string        `env:"RESET_URL" envDefault:"http://localhost:3000/reset-password"`
+	VerifyTTL time.Duration `env:"VERIFY_TTL" envDefault:"24h"`
+	// VerifyURL is the page of the client that takes the email verification
+	// token, the token is appended as the token query parameter.
+	VerifyURL string `env:"VERIFY_URL" envDefault:"http://localhost:3000/verify-email"`
+}
+
+type accountUseCase struct {
+	userRepo         UserRepository
+	resetRepo        PasswordResetRepository
+	verificationRepo EmailVerificationRepository
+	mailer           mailer.Mailer
+	config           Config
+	// dummyHash is compared against when the email is unknown, so a login
+	// takes as long whether or not the account exists
+	dummyHash []byte
+}
+
+func NewAccountUseCase(userRepo UserRepository, resetRepo PasswordResetRepository, verificationRepo EmailVerificationRepository, mailer mailer.Mailer, config Config) *accountUseCase {
+	if config.HashCost < bcrypt.MinCost || config.HashCost > bcrypt.MaxCost {
+		config.HashCost = bcrypt.DefaultCost
+	}
+	if config.ResetTTL <= 0 {
+		config.ResetTTL = time.Hour
+	}
+	if config.VerifyTTL <= 0 {
+		config.VerifyTTL = 24 * time.Hour
+	}
+
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("dummy-password"), config.HashCost)
+	if err != nil {
+		log.Printf("failed to create dummy password hash: %v", err)
+	}
+
+	return &accountUseCase{
+		userRepo:         userRepo,
+		resetRepo:        resetRepo,
+		verificationRepo: verificationRepo,
+		mailer:           mailer,
+		config:           config,
+		dummyHash:        dummyHash,
+	}
+}
+
+// Register creates a local account and mails a link to verify its email, the
+// account cannot sign in until the link is followed. When the email belongs to
+// a Google account, the password is added to that account once the link is
+// followed instead.
+func (a *accountUseCase) Register(ctx context.Context, name, email, password string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return apperror.BadRequestError(errors.New("empty name"), "name is required")
+	}
+
+	hash, err := a.hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	existing, err := a.userRepo.GetUserByEmail(email)
+	switch {
+	case err == nil && existing.PasswordHash != "":
+		return apperror.ConflictError(errors.New("email has a password"), "email is already registered")
+	case err == nil:
+		return a.sendVerification(ctx, existing, hash)
+	case !hasCode(err, fiber.StatusNotFound):
+		return err
+	}
+
+	user, err := a.userRepo.CreateUser(&domain.User{
+		Name:         name,
+		Email:        email,
+		PasswordHash: hash,
+		Provider:     domain.ProviderLocal,
+		ProviderID:   email,
+	})
+	if err != nil {
+		if hasCode(err, fiber.StatusConflict) {
+			return apperror.ConflictError(err, "email is already registered")
+		}
+		return err
+	}
+	return a.sendVerification(ctx, user, "")
+}
+
+// VerifyEmail marks the email of a user verified with a token from Register
+// or ResendVerification, and returns the user.
+func (a *accountUseCase) VerifyEmail(token string) (*domain.User, error) {
+	invalid := apperror.BadRequestError(errors.New("invalid email verification token"), "verification link is invalid or has expired")
+
+	verification, err := a.verificationRepo.FindByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if verification == nil || verification.UsedAt != nil || !time.Now().Before(verification.ExpiresAt) {
+		return nil, invalid
+	}
+
+	used, err := a.verificationRepo.Use(verification.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, invalid
+	}
+
+	if verification.PasswordHash != "" {
+		if err := a.userRepo.UpdatePasswordHash(verification.UserID, verification.PasswordHash); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.userRepo.MarkEmailVerified(verification.UserID); err != nil {
+		return nil, err
+	}
+	return a.userRepo.GetUserByID(verification.UserID)
+}
+
+// ResendVerification mails a new verification link to the owner of an
+// unverified account. Nothing tells the caller whether the email belongs to
+// an account.
+func (a *accountUseCase) ResendVerification(ctx context.Context, email string) error {
+	user, err := a.userRepo.GetUserByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		if hasCode(err, fiber.StatusNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerified() {
+		return nil
+	}
+	return a.sendVerification(ctx, user, "")
+}
+
+// sendVerification mails a verification link to user, passwordHash is the
+// password to add to the account once the link is followed, if any.
+func (a *accountUseCase) sendVerification(ctx context.Context, user *domain.User, passwordHash string) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return apperror.InternalServerError(err, "failed to create email verification")
+	}
+	verification := domain.EmailVerification{
+		UserID:       user.ID,
+		TokenHash:    hash,
+		PasswordHash: passwordHash,
+		ExpiresAt:    time.Now().Add(a.config.VerifyTTL),
+	}
+	if err := a.verificationRepo.Create(&verification); err != nil {
+		return err
+	}
+
+	intro := "Follow this link to verify your email"
+	if passwordHash != "" {
+		intro = "Someone asked to add a password to your account. Follow this link to allow it"
+	}
+	link := a.config.VerifyURL + "?token=" + url.QueryEscape(token)
+	if err := a.mailer.Send(ctx, mailer.Mail{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\n%s, it expires in %s:\n%s\n\nIf you did not ask for this, you can ignore this mail.",
+			user.Name, intro, a.config.VerifyTTL, link),
+	}); err != nil {
+		// failing here would tell the caller whether the email has a Google account,
+		// a link can be mailed again with ResendVerification
+		log.Printf("failed to mail email verification to user %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+// Login checks an email and password. Unknown emails, accounts without a
+// password and wrong passwords fail the same way.
+func (a *accountUseCase) Login(email, password string) (*domain.User, error) {
+	invalid := apperror.UnauthorizedError(errors.New("invalid credentials"), "Invalid email or password")
+
+	user, err := a.userRepo.GetUserByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		if hasCode(err, fiber.StatusNotFound) {
+			_ = bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+			return nil, invalid
+		}
+		return nil, err
+	}
+
+	if user.PasswordHash == "" {
+		_ = bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+		return nil, invalid
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, invalid
+	}
+	if !user.EmailVerified() {
+		return nil, apperror.ForbiddenError(errors.New("email not verified"), "email is not verified, follow the link mailed to you")
+	}
+	return user, nil
+}
+
+func (a *accountUseCase) ChangePassword(userID, currentPassword, newPassword string) error {
+	user, err := a.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash == "" {
+		return apperror.BadRequestError(errors.New("account without password"), "account has no password, sign in with Google")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)); err != nil {
+		return apperror.UnauthorizedError(err, "current password is incorrect")
+	}
+
+	hash, err := a.hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return a.userRepo.UpdatePasswordHash(user.ID, hash)
+}
+
+// RequestPasswordReset mails a reset link to the owner of an account with a
+// password. Nothing tells the caller whether the email belongs to an account.
+func (a *accountUseCase) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := a.userRepo.GetUserByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		if hasCode(err, fiber.StatusNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.PasswordHash == "" {
+		return nil
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return apperror.InternalServerError(err, "failed to create password reset")
+	}
+	reset := domain.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(a.config.ResetTTL),
+	}
+	if err := a.resetRepo.Create(&reset); err != nil {
+		return err
+	}
+
+	link := a.config.ResetURL + "?token=" + url.QueryEscape(token)
+	if err := a.mailer.Send(ctx, mailer.Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nFollow this link to choose a new password, it expires in %s:\n%s\n\nIf you did not ask for this, you can ignore this mail.",
+			user.Name, a.config.ResetTTL, link),
+	}); err != nil {
+		// failing here would tell the caller that the account exists
+		log.Printf("failed to mail password reset to user %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a token from RequestPasswordReset,
+// and returns the user whose password changed. The other reset links of the
+// user stop working. The link was mailed to the user, so following it
+// verifies their email too.
+func (a *accountUseCase) ResetPassword(token, newPassword string) (*domain.User, error) {
+	invalid := apperror.BadRequestError(errors.New("invalid password reset token"), "reset link is invalid or has expired")
+
+	reset, err := a.resetRepo.FindByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if reset == nil || reset.UsedAt != nil || !time.Now().Before(reset.ExpiresAt) {
+		return nil, invalid
+	}
+
+	hash, err := a.hashPassword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := a.resetRepo.Use(reset.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, invalid
+	}
+	// the other links mailed before this one would undo the new password
+	if err := a.resetRepo.UseByUserID(reset.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := a.userRepo.UpdatePasswordHash(reset.UserID, hash); err != nil {
+		return nil, err
+	}
+	if err := a.userRepo.MarkEmailVerified(reset.UserID); err != nil {
+		return nil, err
+	}
+	return a.userRepo.GetUserByID(reset.UserID)
+}
+
+func (a *accountUseCase) hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", apperror.BadRequestError(errors.New("password too short"), fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+	}
+	if len(password) > maxPasswordLength {
+		return "", apperror.BadRequestError(errors.New("password too long"), fmt.Sprintf("password must be at most %d bytes", maxPasswordLength))
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), a.config.HashCost)
+	if err != nil {
+		return "", apperror.InternalServerError(err, "failed to hash password")
+	}
+	return string(hash), nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || address.Name != "" {
+		return "", apperror.BadRequestError(fmt.Errorf("invalid email %q", email), "email is invalid")
+	}
+	return strings.ToLower(address.Address), nil
+}
+
+func hasCode(err error, code int) bool {
+	appErr, ok := err.(*apperror.AppError)
+	return ok && appErr.Code == code
+}
+
+func newToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,349 @@
+package account_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/account"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+	"github.com/yokeTH/chat-app-backend/pkg/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type memoryUsers struct {
+	users map[string]*domain.User
+}
+
+func (r *memoryUsers) GetUserByID(id string) (*domain.User, error) {
+	if user, ok := r.users[id]; ok {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, apperror.NotFoundError(nil, "user not found")
+}
+
+func (r *memoryUsers) GetUserByEmail(email string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, apperror.NotFoundError(nil, "user not found")
+}
+
+func (r *memoryUsers) CreateUser(user *domain.User) (*domain.User, error) {
+	if _, err := r.GetUserByEmail(user.Email); err == nil {
+		return nil, apperror.ConflictError(nil, "user already exists")
+	}
+	user.ID = user.Email
+	r.users[user.ID] = user
+	return user, nil
+}
+
+func (r *memoryUsers) UpdatePasswordHash(userID string, passwordHash string) error {
+	r.users[userID].PasswordHash = passwordHash
+	return nil
+}
+
+func (r *memoryUsers) MarkEmailVerified(userID string) error {
+	if r.users[userID].EmailVerifiedAt == nil {
+		now := time.Now()
+		r.users[userID].EmailVerifiedAt = &now
+	}
+	return nil
+}
+
+type memoryResets struct {
+	resets map[string]*domain.PasswordReset
+}
+
+func (r *memoryResets) Create(reset *domain.PasswordReset) error {
+	reset.ID = reset.TokenHash
+	r.resets[reset.ID] = reset
+	return nil
+}
+
+func (r *memoryResets) FindByTokenHash(hash string) (*domain.PasswordReset, error) {
+	if reset, ok := r.resets[hash]; ok {
+		copied := *reset
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *memoryResets) UseByUserID(userID string) error {
+	now := time.Now()
+	for _, reset := range r.resets {
+		if reset.UserID == userID && reset.UsedAt == nil {
+			reset.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *memoryResets) Use(id string) (bool, error) {
+	reset := r.resets[id]
+	if reset.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	reset.UsedAt = &now
+	return true, nil
+}
+
+type memoryVerifications struct {
+	verifications map[string]*domain.EmailVerification
+}
+
+func (r *memoryVerifications) Create(verification *domain.EmailVerification) error {
+	verification.ID = verification.TokenHash
+	r.verifications[verification.ID] = verification
+	return nil
+}
+
+func (r *memoryVerifications) FindByTokenHash(hash string) (*domain.EmailVerification, error) {
+	if verification, ok := r.verifications[hash]; ok {
+		copied := *verification
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *memoryVerifications) Use(id string) (bool, error) {
+	verification := r.verifications[id]
+	if verification.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	verification.UsedAt = &now
+	return true, nil
+}
+
+type sentMails interface{ Sent() []mailer.Mail }
+
+func newUseCase() (account.AccountUseCase, *memoryUsers, sentMails) {
+	users := &memoryUsers{users: make(map[string]*domain.User)}
+	mails := mailer.NewMemory("no-reply@example.com")
+	uc := account.NewAccountUseCase(users, &memoryResets{resets: make(map[string]*domain.PasswordReset)}, &memoryVerifications{verifications: make(map[string]*domain.EmailVerification)}, mails, account.Config{
+		HashCost:  bcrypt.MinCost,
+		ResetURL:  "http://example.com/reset",
+		VerifyURL: "http://example.com/verify",
+	})
+	return uc, users, mails
+}
+
+// register creates an account and follows the verification link mailed for it.
+func register(t *testing.T, uc account.AccountUseCase, mails sentMails, name, email, password string) *domain.User {
+	t.Helper()
+	assert.Nil(t, uc.Register(context.Background(), name, email, password))
+
+	sent := mails.Sent()
+	user, err := uc.VerifyEmail(mailedToken(t, sent[len(sent)-1].Body, "http://example.com/verify?"))
+	assert.Nil(t, err)
+	return user
+}
+
+func TestAccountLogin(t *testing.T) {
+	uc, users, mails := newUseCase()
+
+	registered := register(t, uc, mails, "Alice", " Alice@Example.com ", "correct horse")
+	assert.Equal(t, "alice@example.com", registered.Email)
+	assert.Equal(t, domain.ProviderLocal, registered.Provider)
+	assert.True(t, registered.EmailVerified())
+
+	users.users["google"] = &domain.User{ID: "google", Email: "bob@example.com", Provider: domain.ProviderGoogle}
+	assert.Nil(t, uc.Register(context.Background(), "Carol", "carol@example.com", "correct horse"))
+
+	tests := []struct {
+		description string
+		email       string
+		password    string
+		success     bool
+	}{
+		{
+			description: "valid credentials",
+			email:       "alice@example.com",
+			password:    "correct horse",
+			success:     true,
+		},
+		{
+			description: "email in another case",
+			email:       "ALICE@example.com",
+			password:    "correct horse",
+			success:     true,
+		},
+		{
+			description: "wrong password",
+			email:       "alice@example.com",
+			password:    "battery staple",
+		},
+		{
+			description: "unknown email",
+			email:       "dave@example.com",
+			password:    "correct horse",
+		},
+		{
+			description: "unverified email",
+			email:       "carol@example.com",
+			password:    "correct horse",
+		},
+		{
+			description: "account without password",
+			email:       "bob@example.com",
+			password:    "",
+		},
+	}
+
+	for _, test := range tests {
+		user, err := uc.Login(test.email, test.password)
+		if !test.success {
+			assert.NotNilf(t, err, test.description)
+			continue
+		}
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, registered.ID, user.ID, test.description)
+	}
+}
+
+func TestAccountRegisterInvalid(t *testing.T) {
+	uc, _, _ := newUseCase()
+
+	assert.Nil(t, uc.Register(context.Background(), "Alice", "alice@example.com", "correct horse"))
+
+	tests := []struct {
+		description string
+		name        string
+		email       string
+		password    string
+	}{
+		{description: "duplicate email", name: "Alice", email: "ALICE@example.com", password: "correct horse"},
+		{description: "invalid email", name: "Alice", email: "alice", password: "correct horse"},
+		{description: "short password", name: "Alice", email: "alice2@example.com", password: "short"},
+		{description: "empty name", name: " ", email: "alice3@example.com", password: "correct horse"},
+	}
+
+	for _, test := range tests {
+		err := uc.Register(context.Background(), test.name, test.email, test.password)
+		assert.NotNilf(t, err, test.description)
+	}
+}
+
+func TestAccountVerifyEmail(t *testing.T) {
+	uc, _, mails := newUseCase()
+
+	assert.Nil(t, uc.Register(context.Background(), "Alice", "alice@example.com", "correct horse"))
+	assert.Nil(t, uc.ResendVerification(context.Background(), "alice@example.com"))
+	assert.Nil(t, uc.ResendVerification(context.Background(), "nobody@example.com"))
+	sent := mails.Sent()
+	assert.Len(t, sent, 2)
+
+	_, err := uc.VerifyEmail("not-a-token")
+	assert.NotNil(t, err)
+
+	user, err := uc.VerifyEmail(mailedToken(t, sent[1].Body, "http://example.com/verify?"))
+	assert.Nil(t, err)
+	assert.True(t, user.EmailVerified())
+
+	_, err = uc.VerifyEmail(mailedToken(t, sent[1].Body, "http://example.com/verify?"))
+	assert.NotNil(t, err, "a verification token works once")
+
+	// a verified account is not mailed again
+	assert.Nil(t, uc.ResendVerification(context.Background(), "alice@example.com"))
+	assert.Len(t, mails.Sent(), 2)
+}
+
+func TestAccountRegisterGoogleEmail(t *testing.T) {
+	uc, users, mails := newUseCase()
+	users.users["google"] = &domain.User{ID: "google", Name: "Bob", Email: "bob@example.com", Provider: domain.ProviderGoogle, ProviderID: "google-1"}
+
+	assert.Nil(t, uc.Register(context.Background(), "Mallory", "bob@example.com", "correct horse"))
+	assert.Len(t, users.users, 1, "the Google account is reused")
+
+	_, err := uc.Login("bob@example.com", "correct horse")
+	assert.NotNil(t, err, "the password is only added once the link is followed")
+
+	sent := mails.Sent()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "bob@example.com", sent[0].To)
+
+	user, err := uc.VerifyEmail(mailedToken(t, sent[0].Body, "http://example.com/verify?"))
+	assert.Nil(t, err)
+	assert.Equal(t, "google", user.ID)
+
+	user, err = uc.Login("bob@example.com", "correct horse")
+	assert.Nil(t, err)
+	assert.Equal(t, "google", user.ID)
+	assert.Equal(t, "Bob", user.Name)
+
+	err = uc.Register(context.Background(), "Bob", "bob@example.com", "battery staple")
+	assert.NotNil(t, err, "the account has a password now")
+}
+
+func TestAccountChangePassword(t *testing.T) {
+	uc, _, mails := newUseCase()
+
+	user := register(t, uc, mails, "Alice", "alice@example.com", "correct horse")
+
+	assert.NotNil(t, uc.ChangePassword(user.ID, "wrong password", "battery staple"))
+	assert.Nil(t, uc.ChangePassword(user.ID, "correct horse", "battery staple"))
+
+	_, err := uc.Login("alice@example.com", "correct horse")
+	assert.NotNil(t, err)
+	_, err = uc.Login("alice@example.com", "battery staple")
+	assert.Nil(t, err)
+}
+
+func TestAccountResetPassword(t *testing.T) {
+	uc, _, mails := newUseCase()
+
+	// the account is not verified, following the reset link verifies it
+	assert.Nil(t, uc.Register(context.Background(), "Alice", "alice@example.com", "correct horse"))
+
+	assert.Nil(t, uc.RequestPasswordReset(context.Background(), "nobody@example.com"))
+	assert.Len(t, mails.Sent(), 1)
+
+	assert.Nil(t, uc.RequestPasswordReset(context.Background(), "alice@example.com"))
+	assert.Nil(t, uc.RequestPasswordReset(context.Background(), "alice@example.com"))
+	sent := mails.Sent()
+	assert.Len(t, sent, 3)
+	assert.Equal(t, "alice@example.com", sent[1].To)
+
+	token := mailedToken(t, sent[1].Body, "http://example.com/reset?")
+	other := mailedToken(t, sent[2].Body, "http://example.com/reset?")
+
+	_, err := uc.ResetPassword("not-a-token", "battery staple")
+	assert.NotNil(t, err)
+
+	user, err := uc.ResetPassword(token, "battery staple")
+	assert.Nil(t, err)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.True(t, user.EmailVerified())
+
+	_, err = uc.ResetPassword(token, "another password")
+	assert.NotNil(t, err, "a reset token works once")
+	_, err = uc.ResetPassword(other, "another password")
+	assert.NotNil(t, err, "a reset ends the other reset links")
+
+	_, err = uc.Login("alice@example.com", "battery staple")
+	assert.Nil(t, err)
+}
+
+func mailedToken(t *testing.T, body, prefix string) string {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		link, err := url.Parse(line)
+		assert.Nil(t, err)
+		return link.Query().Get("token")
+	}
+	t.Fatal("no link in mail")
+	return ""
+}
@@ -0,0 +1,38 @@
+package account
+
+import (
+	"context"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+)
+
+type UserRepository interface {
+	GetUserByID(id string) (*domain.User, error)
+	GetUserByEmail(email string) (*domain.User, error)
+	CreateUser(user *domain.User) (*domain.User, error)
+	UpdatePasswordHash(userID string, passwordHash string) error
+	MarkEmailVerified(userID string) error
+}
+
+type PasswordResetRepository interface {
+	Create(reset *domain.PasswordReset) error
+	FindByTokenHash(hash string) (*domain.PasswordReset, error)
+	Use(id string) (bool, error)
+	UseByUserID(userID string) error
+}
+
+type EmailVerificationRepository interface {
+	Create(verification *domain.EmailVerification) error
+	FindByTokenHash(hash string) (*domain.EmailVerification, error)
+	Use(id string) (bool, error)
+}
+
+type AccountUseCase interface {
+	Register(ctx context.Context, name, email, password string) error
+	VerifyEmail(token string) (*domain.User, error)
+	ResendVerification(ctx context.Context, email string) error
+	Login(email, password string) (*domain.User, error)
+	ChangePassword(userID, currentPassword, newPassword string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(token, newPassword string) (*domain.User, error)
+}
@@ -1,14 +1,24 @@
 package conversation
 
-import "github.com/yokeTH/chat-app-backend/internal/domain"
+import (
+	"fmt"
+	"time"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
 
 type conversationUseCase struct {
-	convRepo ConversationRepository
+	convRepo    ConversationRepository
+	eventRepo   EventRepository
+	messageRepo MessageRepository
 }
 
-func NewConversationUseCase(convRepo ConversationRepository) *conversationUseCase {
+func NewConversationUseCase(convRepo ConversationRepository, eventRepo EventRepository, messageRepo MessageRepository) *conversationUseCase {
 	return &conversationUseCase{
-		convRepo: convRepo,
+		convRepo:    convRepo,
+		eventRepo:   eventRepo,
+		messageRepo: messageRepo,
 	}
 }
 
@@ -16,8 +26,59 @@ func (c *conversationUseCase) GetUserConversations(userID string, limit, page in
 	return c.convRepo.GetUserConversations(userID, limit, page)
 }
 
-func (c *conversationUseCase) CreateConversation(usersID []string, createdByID string, name string) (*domain.Conversation, error) {
-	return c.convRepo.CreateConversation(usersID, createdByID, name)
+// CreateConversation creates the conversation and the system message
+// announcing it, the caller broadcasts the message.
+func (c *conversationUseCase) CreateConversation(usersID []string, creator *domain.User, name string) (*domain.Conversation, *domain.Message, error) {
+	conversation, err := c.convRepo.CreateConversation(usersID, creator.ID, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	system, err := c.createSystemMessage(conversation.ID, fmt.Sprintf("Chat has been created by %s", creator.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+	return conversation, system, nil
+}
+
+// JoinConversation adds the user to the conversation and creates the system
+// message announcing it, the caller broadcasts both.
+func (c *conversationUseCase) JoinConversation(conversationID string, user *domain.User) (*domain.Conversation, *domain.Message, error) {
+	if err := c.Authorize(conversationID, user.ID, ActionJoin); err != nil {
+		return nil, nil, err
+	}
+	if err := c.convRepo.AddMemberToConversation(conversationID, user.ID); err != nil {
+		return nil, nil, err
+	}
+
+	conversation, err := c.convRepo.GetConversation(conversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	system, err := c.createSystemMessage(conversationID, fmt.Sprintf("%s has entered the chat", user.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+	return conversation, system, nil
+}
+
+func (c *conversationUseCase) createSystemMessage(conversationID, content string) (*domain.Message, error) {
+	message := &domain.Message{
+		ConversationID: conversationID,
+		Content:        content,
+		MessageType:    domain.MessageTypeSystem,
+	}
+
+	if err := c.messageRepo.Create(message); err != nil {
+		return nil, apperror.InternalServerError(err, "failed to create message")
+	}
+
+	message, err := c.messageRepo.FindByID(message.ID)
+	if err != nil {
+		return nil, apperror.InternalServerError(err, "failed to get message")
+	}
+	return message, nil
 }
 
 func (c *conversationUseCase) GetMembers(id string) (*[]domain.User, error) {
@@ -28,6 +89,108 @@ func (c *conversationUseCase) GetConversation(id string) (*domain.Conversation,
 	return c.convRepo.GetConversation(id)
 }
 
-func (c *conversationUseCase) AddMember(conversationID, userID string) error {
-	return c.convRepo.AddMemberToConversation(conversationID, userID)
+// GetUserConversation returns the conversation with the user's unread count
+// if the user may read it.
+func (c *conversationUseCase) GetUserConversation(id, userID string) (*domain.Conversation, error) {
+	if err := c.Authorize(id, userID, ActionRead); err != nil {
+		return nil, err
+	}
+
+	conversation, err := c.convRepo.GetConversation(id)
+	if err != nil {
+		return nil, err
+	}
+
+	conversation.UnreadCount, err = c.convRepo.CountUnread(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+func (c *conversationUseCase) MarkAsRead(conversationID, userID, messageID string) (*domain.ConversationMember, error) {
+	if err := c.Authorize(conversationID, userID, ActionWrite); err != nil {
+		return nil, err
+	}
+	return c.convRepo.MarkAsRead(conversationID, userID, messageID)
+}
+
+func (c *conversationUseCase) GetUnreadCount(conversationID, userID string) (int, error) {
+	return c.convRepo.CountUnread(conversationID, userID)
+}
+
+// AppendEvent records a conversation event under the next sequence number of the conversation.
+func (c *conversationUseCase) AppendEvent(conversationID, event string, payload []byte) (*domain.ConversationEvent, error) {
+	conversationEvent := &domain.ConversationEvent{
+		ConversationID: conversationID,
+		Event:          event,
+		Payload:        string(payload),
+	}
+	if err := c.eventRepo.Create(conversationEvent); err != nil {
+		return nil, err
+	}
+	return conversationEvent, nil
+}
+
+// GetEventsAfter returns the events after seq. complete is false when some
+// of them were already pruned, the caller must then refetch the conversation.
+func (c *conversationUseCase) GetEventsAfter(conversationID, userID string, seq int64, limit int) (*[]domain.ConversationEvent, bool, error) {
+	if err := c.Authorize(conversationID, userID, ActionRead); err != nil {
+		return nil, false, err
+	}
+
+	conversation, err := c.convRepo.GetConversationByID(conversationID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	events, err := c.eventRepo.FindAfterSeq(conversationID, seq, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	return events, seq >= conversation.PrunedSeq, nil
+}
+
+// PruneEvents deletes the events older than the replay window.
+func (c *conversationUseCase) PruneEvents(olderThan time.Duration) (int64, error) {
+	return c.eventRepo.Prune(time.Now().Add(-olderThan))
+}
+
+// Authorize is the single place that decides whether userID may perform action
+// in the conversation. The use cases acting for a user call it themselves.
+func (c *conversationUseCase) Authorize(conversationID, userID string, action Action) error {
+	isMember, err := c.convRepo.IsMember(conversationID, userID)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case ActionRead, ActionWrite:
+		if !isMember {
+			return apperror.ForbiddenError(fmt.Errorf("user %s is not a member of conversation %s", userID, conversationID), "not a member of this conversation")
+		}
+		return nil
+
+	case ActionJoin:
+		if isMember {
+			return apperror.ConflictError(fmt.Errorf("user %s is already a member of conversation %s", userID, conversationID), "already a member of this conversation")
+		}
+		conversation, err := c.convRepo.GetConversationByID(conversationID)
+		if err != nil {
+			return err
+		}
+		if !conversation.IsGroup {
+			return apperror.ForbiddenError(fmt.Errorf("conversation %s is not a group", conversationID), "only group conversations can be joined")
+		}
+		return nil
+
+	default:
+		return apperror.ForbiddenError(fmt.Errorf("unknown conversation action: %s", action), "action not allowed")
+	}
+}
+
+// GetContactIDs returns the users that share a conversation with userID,
+// they are the only ones who get to see the user's presence.
+func (c *conversationUseCase) GetContactIDs(userID string) ([]string, error) {
+	return c.convRepo.GetContactIDs(userID)
 }
@@ -1,6 +1,22 @@
 package conversation
 
-import "github.com/yokeTH/chat-app-backend/internal/domain"
+import (
+	"time"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+)
+
+// Action is something a user may attempt in a conversation, see ConversationUseCase.Authorize.
+type Action string
+
+const (
+	// ActionRead covers viewing the conversation, its history and read receipts
+	ActionRead Action = "read"
+	// ActionWrite covers sending messages and files, reacting, typing and read receipts
+	ActionWrite Action = "write"
+	// ActionJoin covers adding oneself to a group conversation
+	ActionJoin Action = "join"
+)
 
 type ConversationRepository interface {
 	GetUserConversations(userID string, limit, page int) (*[]domain.Conversation, int, int, error)
@@ -9,12 +25,37 @@ type ConversationRepository interface {
 	GetConversation(id string) (*domain.Conversation, error)
 	GetUserNotInConversations(userID string, limit, page int) (*[]domain.Conversation, int, int, error)
 	AddMemberToConversation(conversationID, userID string) error
+	MarkAsRead(conversationID, userID, messageID string) (*domain.ConversationMember, error)
+	CountUnread(conversationID, userID string) (int, error)
+	GetConversationByID(id string) (*domain.Conversation, error)
+	IsMember(conversationID, userID string) (bool, error)
+	GetContactIDs(userID string) ([]string, error)
+}
+
+// MessageRepository stores the system messages announcing conversation changes.
+type MessageRepository interface {
+	Create(message *domain.Message) error
+	FindByID(id string) (*domain.Message, error)
+}
+
+type EventRepository interface {
+	Create(event *domain.ConversationEvent) error
+	FindAfterSeq(conversationID string, seq int64, limit int) (*[]domain.ConversationEvent, error)
+	Prune(before time.Time) (int64, error)
 }
 
 type ConversationUseCase interface {
 	GetUserConversations(userID string, limit, page int) (*[]domain.Conversation, int, int, error)
-	CreateConversation(usersID []string, createdByID string, name string) (*domain.Conversation, error)
+	CreateConversation(usersID []string, creator *domain.User, name string) (*domain.Conversation, *domain.Message, error)
+	JoinConversation(conversationID string, user *domain.User) (*domain.Conversation, *domain.Message, error)
 	GetMembers(id string) (*[]domain.User, error)
 	GetConversation(id string) (*domain.Conversation, error)
-	AddMember(conversationID, userID string) error
+	GetUserConversation(id, userID string) (*domain.Conversation, error)
+	MarkAsRead(conversationID, userID, messageID string) (*domain.ConversationMember, error)
+	GetUnreadCount(conversationID, userID string) (int, error)
+	AppendEvent(conversationID, event string, payload []byte) (*domain.ConversationEvent, error)
+	GetEventsAfter(conversationID, userID string, seq int64, limit int) (*[]domain.ConversationEvent, bool, error)
+	PruneEvents(olderThan time.Duration) (int64, error)
+	Authorize(conversationID, userID string, action Action) error
+	GetContactIDs(userID string) ([]string, error)
 }
@@ -3,23 +3,34 @@ package message
 import (
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
 	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
 )
 
 type MessageRepository interface {
 	Create(message *domain.Message) error
 	FindByID(id string) (*domain.Message, error)
+	FindByClientMessageID(senderID, clientMessageID string) (*domain.Message, error)
 	FindByConversationID(conversationID string) (*[]domain.Message, error)
 	FindByConversationIDPaginated(convoID string, limit, page int) (*[]domain.Message, int, int, error)
+	FindAfterSeq(conversationID string, seq int64, limit int) (*[]domain.Message, error)
 	Update(message *domain.Message) error
 	Delete(id string) error
 	SoftDelete(id string) error
 }
 
+// Authorizer decides whether a user may act in a conversation, it is
+// implemented by the conversation use case.
+type Authorizer interface {
+	Authorize(conversationID, userID string, action conversation.Action) error
+}
+
 type MessageUseCase interface {
 	Create(senderID string, req dto.CreateMessageRequest) (*domain.Message, error)
-	CreateSystemMessage(conversationID string, content string) (*domain.Message, error)
+	Send(senderID string, req dto.CreateMessageRequest) (*domain.Message, bool, error)
 	GetByID(id string) (*domain.Message, error)
+	GetUserMessage(id, userID string) (*domain.Message, error)
 	GetByConversationID(convoID string) (*[]domain.Message, error)
-	GetByConversationPaginated(convoID string, limit, page int) (*[]domain.Message, int, int, error)
+	GetByConversationPaginated(convoID, userID string, limit, page int) (*[]domain.Message, int, int, error)
+	GetAfterSeq(convoID, userID string, seq int64, limit int) (*[]domain.Message, error)
 	SoftDelete(id string) error
 }
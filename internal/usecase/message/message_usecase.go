@@ -1,22 +1,45 @@
 package message
 
 import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
 	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
 	"github.com/yokeTH/chat-app-backend/pkg/apperror"
 )
 
+const maxClientMessageIDLength = 64
+
 type messageUseCase struct {
-	repo MessageRepository
+	repo       MessageRepository
+	authorizer Authorizer
 }
 
-func NewMessageUseCase(repo MessageRepository) *messageUseCase {
+func NewMessageUseCase(repo MessageRepository, authorizer Authorizer) *messageUseCase {
 	return &messageUseCase{
-		repo: repo,
+		repo:       repo,
+		authorizer: authorizer,
 	}
 }
 
 func (uc *messageUseCase) Create(senderID string, req dto.CreateMessageRequest) (*domain.Message, error) {
+	message, _, err := uc.Send(senderID, req)
+	return message, err
+}
+
+// Send creates the message unless the sender already sent one with the same
+// client message id, then the existing message is returned and created is false.
+func (uc *messageUseCase) Send(senderID string, req dto.CreateMessageRequest) (*domain.Message, bool, error) {
+	if len(req.ClientMessageID) > maxClientMessageIDLength {
+		return nil, false, apperror.BadRequestError(errors.New("client message id too long"), "client message id is too long")
+	}
+
+	if err := uc.authorizer.Authorize(req.ConversationID, senderID, conversation.ActionWrite); err != nil {
+		return nil, false, err
+	}
+
 	message := &domain.Message{
 		ConversationID: req.ConversationID,
 		SenderID:       senderID,
@@ -24,41 +47,58 @@ func (uc *messageUseCase) Create(senderID string, req dto.CreateMessageRequest)
 		MessageType:    domain.MessageTypeText,
 	}
 
+	if req.ClientMessageID != "" {
+		existing, err := uc.repo.FindByClientMessageID(senderID, req.ClientMessageID)
+		if err == nil {
+			return existing, false, nil
+		}
+		if !hasCode(err, fiber.StatusNotFound) {
+			return nil, false, err
+		}
+		message.ClientMessageID = &req.ClientMessageID
+	}
+
 	if err := uc.repo.Create(message); err != nil {
-		return nil, apperror.InternalServerError(err, "failed to create message")
+		// lost the race against a concurrent retry with the same key
+		if hasCode(err, fiber.StatusConflict) && req.ClientMessageID != "" {
+			existing, err := uc.repo.FindByClientMessageID(senderID, req.ClientMessageID)
+			if err != nil {
+				return nil, false, err
+			}
+			return existing, false, nil
+		}
+		if apperror.IsAppError(err) {
+			return nil, false, err
+		}
+		return nil, false, apperror.InternalServerError(err, "failed to create message")
 	}
 
 	message, err := uc.repo.FindByID(message.ID)
 	if err != nil {
-		return nil, apperror.InternalServerError(err, "failed to get message")
+		return nil, false, apperror.InternalServerError(err, "failed to get message")
 	}
 
-	return message, nil
+	return message, true, nil
 }
 
-func (uc *messageUseCase) CreateSystemMessage(conversationID string, content string) (*domain.Message, error) {
-	message := &domain.Message{
-		ConversationID: conversationID,
-		Content:        content,
-		MessageType:    domain.MessageTypeSystem,
-	}
-
-	if err := uc.repo.Create(message); err != nil {
-		return nil, apperror.InternalServerError(err, "failed to create message")
-	}
-
-	message, err := uc.repo.FindByID(message.ID)
+func (uc *messageUseCase) GetByID(id string) (*domain.Message, error) {
+	message, err := uc.repo.FindByID(id)
 	if err != nil {
-		return nil, apperror.InternalServerError(err, "failed to get message")
+		return nil, apperror.NotFoundError(err, "message not found")
 	}
 
 	return message, nil
 }
 
-func (uc *messageUseCase) GetByID(id string) (*domain.Message, error) {
-	message, err := uc.repo.FindByID(id)
+// GetUserMessage returns the message if userID may read its conversation.
+func (uc *messageUseCase) GetUserMessage(id, userID string) (*domain.Message, error) {
+	message, err := uc.GetByID(id)
 	if err != nil {
-		return nil, apperror.NotFoundError(err, "message not found")
+		return nil, err
+	}
+
+	if err := uc.authorizer.Authorize(message.ConversationID, userID, conversation.ActionRead); err != nil {
+		return nil, err
 	}
 
 	return message, nil
@@ -79,6 +119,21 @@ func (uc *messageUseCase) SoftDelete(id string) error {
 	return nil
 }
 
-func (uc *messageUseCase) GetByConversationPaginated(convoID string, limit, page int) (*[]domain.Message, int, int, error) {
+func (uc *messageUseCase) GetByConversationPaginated(convoID, userID string, limit, page int) (*[]domain.Message, int, int, error) {
+	if err := uc.authorizer.Authorize(convoID, userID, conversation.ActionRead); err != nil {
+		return nil, 0, 0, err
+	}
 	return uc.repo.FindByConversationIDPaginated(convoID, limit, page)
 }
+
+func (uc *messageUseCase) GetAfterSeq(convoID, userID string, seq int64, limit int) (*[]domain.Message, error) {
+	if err := uc.authorizer.Authorize(convoID, userID, conversation.ActionRead); err != nil {
+		return nil, err
+	}
+	return uc.repo.FindAfterSeq(convoID, seq, limit)
+}
+
+func hasCode(err error, code int) bool {
+	appErr, ok := err.(*apperror.AppError)
+	return ok && appErr.Code == code
+}
@@ -0,0 +1,27 @@
+package reaction
+
+import (
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
+)
+
+type ReactionRepository interface {
+	Create(reaction *domain.Reaction) error
+	Delete(messageID, userID, emoji string) error
+	FindByMessageID(messageID string) (*[]domain.Reaction, error)
+}
+
+type MessageRepository interface {
+	FindByID(id string) (*domain.Message, error)
+}
+
+// Authorizer decides whether a user may act in a conversation, it is
+// implemented by the conversation use case.
+type Authorizer interface {
+	Authorize(conversationID, userID string, action conversation.Action) error
+}
+
+type ReactionUseCase interface {
+	AddReaction(userID, messageID, emoji string) (*domain.Message, error)
+	RemoveReaction(userID, messageID, emoji string) (*domain.Message, error)
+}
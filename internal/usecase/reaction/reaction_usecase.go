@@ -0,0 +1,93 @@
+package reaction
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+)
+
+const maxEmojiLength = 10
+
+type reactionUseCase struct {
+	reactionRepo ReactionRepository
+	messageRepo  MessageRepository
+	authorizer   Authorizer
+}
+
+func NewReactionUseCase(reactionRepo ReactionRepository, messageRepo MessageRepository, authorizer Authorizer) *reactionUseCase {
+	return &reactionUseCase{
+		reactionRepo: reactionRepo,
+		messageRepo:  messageRepo,
+		authorizer:   authorizer,
+	}
+}
+
+// AddReaction reacts to the message and returns it with the updated reaction set.
+func (uc *reactionUseCase) AddReaction(userID, messageID, emoji string) (*domain.Message, error) {
+	if err := validateEmoji(emoji); err != nil {
+		return nil, err
+	}
+
+	message, err := uc.messageRepo.FindByID(messageID)
+	if err != nil {
+		return nil, apperror.NotFoundError(err, "message not found")
+	}
+
+	if err := uc.authorizer.Authorize(message.ConversationID, userID, conversation.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	if err := uc.reactionRepo.Create(&domain.Reaction{
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+	}); err != nil {
+		return nil, err
+	}
+
+	return uc.withReactions(message)
+}
+
+// RemoveReaction removes the user's reaction and returns the message with the updated reaction set.
+func (uc *reactionUseCase) RemoveReaction(userID, messageID, emoji string) (*domain.Message, error) {
+	if err := validateEmoji(emoji); err != nil {
+		return nil, err
+	}
+
+	message, err := uc.messageRepo.FindByID(messageID)
+	if err != nil {
+		return nil, apperror.NotFoundError(err, "message not found")
+	}
+
+	if err := uc.authorizer.Authorize(message.ConversationID, userID, conversation.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	if err := uc.reactionRepo.Delete(messageID, userID, emoji); err != nil {
+		return nil, err
+	}
+
+	return uc.withReactions(message)
+}
+
+func (uc *reactionUseCase) withReactions(message *domain.Message) (*domain.Message, error) {
+	reactions, err := uc.reactionRepo.FindByMessageID(message.ID)
+	if err != nil {
+		return nil, err
+	}
+	message.Reactions = *reactions
+	return message, nil
+}
+
+func validateEmoji(emoji string) error {
+	if emoji == "" {
+		return apperror.BadRequestError(errors.New("empty emoji"), "emoji is required")
+	}
+	if utf8.RuneCountInString(emoji) > maxEmojiLength {
+		return apperror.BadRequestError(errors.New("emoji too long"), "emoji is too long")
+	}
+	return nil
+}
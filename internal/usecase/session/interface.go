@@ -0,0 +1,27 @@
+package session
+
+import (
+	"time"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/ticket"
+)
+
+type SessionRepository interface {
+	Create(session *domain.Session) error
+	FindByID(id string) (*domain.Session, error)
+	FindByTokenHash(hash string) (*domain.Session, error)
+	FindByPreviousTokenHash(hash string) (*domain.Session, error)
+	Rotate(id, oldHash, newHash string, expiresAt time.Time) (bool, error)
+	Revoke(id string) error
+	RevokeByUserID(userID string) error
+	Epoch(userID string) (int64, error)
+}
+
+type SessionUseCase interface {
+	Start(userID string) (*domain.SessionTokens, error)
+	Refresh(refreshToken string) (*domain.SessionTokens, error)
+	Logout(refreshToken string) (string, error)
+	LogoutAll(userID string) error
+	Authenticate(accessToken string) (*ticket.Claims, error)
+}
@@ -0,0 +1,206 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/pkg/apperror"
+	"github.com/yokeTH/chat-app-backend/pkg/ticket"
+)
+
+// reuseGrace is how long the refresh token a session just replaced is still
+// tolerated, so a client that refreshes twice in a row, from two tabs or
+// after a lost response, is not logged out for it.
+const reuseGrace = 10 * time.Second
+
+type Config struct {
+	// Secret signs the access tokens, it is required and every replica must share it.
+	Secret     string        `env:"SECRET"`
+	AccessTTL  time.Duration `env:"ACCESS_TTL" envDefault:"15m"`
+	RefreshTTL time.Duration `env:"REFRESH_TTL" envDefault:"720h"`
+}
+
+type sessionUseCase struct {
+	sessionRepo  SessionRepository
+	accessTokens ticket.Ticketer
+	refreshTTL   time.Duration
+}
+
+// NewSessionUseCase creates the use case behind first-party logins. Access
+// tokens are signed by accessTokens and carry the session epoch of the user,
+// checking one only looks the epoch up. Refresh tokens are opaque and stored
+// hashed by sessionRepo.
+func NewSessionUseCase(sessionRepo SessionRepository, accessTokens ticket.Ticketer, config Config) *sessionUseCase {
+	refreshTTL := config.RefreshTTL
+	if refreshTTL <= 0 {
+		refreshTTL = 30 * 24 * time.Hour
+	}
+	return &sessionUseCase{
+		sessionRepo:  sessionRepo,
+		accessTokens: accessTokens,
+		refreshTTL:   refreshTTL,
+	}
+}
+
+func (s *sessionUseCase) Start(userID string) (*domain.SessionTokens, error) {
+	refreshToken, hash, err := newRefreshToken()
+	if err != nil {
+		return nil, apperror.InternalServerError(err, "failed to create session")
+	}
+
+	session := domain.Session{
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+	if err := s.sessionRepo.Create(&session); err != nil {
+		return nil, err
+	}
+
+	return s.tokens(userID, session.ID, refreshToken, session.ExpiresAt)
+}
+
+// Refresh trades a refresh token for a new pair of tokens. A refresh token
+// works once, presenting one that was already replaced means it leaked, so
+// the whole session is revoked.
+func (s *sessionUseCase) Refresh(refreshToken string) (*domain.SessionTokens, error) {
+	hash := hashToken(refreshToken)
+
+	session, err := s.sessionRepo.FindByTokenHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, s.reused(hash)
+	}
+	if !session.Active() {
+		return nil, apperror.UnauthorizedError(errors.New("refresh of an inactive session"), "Session has expired")
+	}
+
+	newToken, newHash, err := newRefreshToken()
+	if err != nil {
+		return nil, apperror.InternalServerError(err, "failed to refresh session")
+	}
+	expiresAt := time.Now().Add(s.refreshTTL)
+
+	rotated, err := s.sessionRepo.Rotate(session.ID, hash, newHash, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// a concurrent refresh won the race, the same as presenting a replaced token
+		return nil, s.reused(hash)
+	}
+
+	return s.tokens(session.UserID, session.ID, newToken, expiresAt)
+}
+
+// reused handles a refresh token that is not the current token of any session.
+func (s *sessionUseCase) reused(hash string) error {
+	session, err := s.sessionRepo.FindByPreviousTokenHash(hash)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return apperror.UnauthorizedError(errors.New("unknown refresh token"), "Invalid refresh token")
+	}
+
+	if time.Since(session.UpdatedAt) > reuseGrace {
+		log.Printf("refresh token of session %s was reused, revoking the session of user %s", session.ID, session.UserID)
+		if err := s.sessionRepo.Revoke(session.ID); err != nil {
+			return err
+		}
+	}
+	return apperror.UnauthorizedError(errors.New("replaced refresh token"), "Refresh token has already been used")
+}
+
+// Logout revokes the session of refreshToken, along with the access tokens
+// issued in it, and returns the session ID. Logging out twice, or with a
+// token that is no longer valid, is not an error and returns an empty ID.
+func (s *sessionUseCase) Logout(refreshToken string) (string, error) {
+	session, err := s.sessionRepo.FindByTokenHash(hashToken(refreshToken))
+	if err != nil {
+		return "", err
+	}
+	if session == nil || session.RevokedAt != nil {
+		return "", nil
+	}
+	if err := s.sessionRepo.Revoke(session.ID); err != nil {
+		return "", err
+	}
+	return session.ID, nil
+}
+
+// LogoutAll revokes every session of a user, on every device, along with the
+// access tokens already issued to them.
+func (s *sessionUseCase) LogoutAll(userID string) error {
+	return s.sessionRepo.RevokeByUserID(userID)
+}
+
+// Authenticate returns the claims of an access token, naming the user and
+// the session it was issued to. A token of a session that was logged out, or
+// issued before the last LogoutAll of the user, is rejected.
+func (s *sessionUseCase) Authenticate(accessToken string) (*ticket.Claims, error) {
+	claims, err := s.accessTokens.Verify(accessToken)
+	if err != nil {
+		if errors.Is(err, ticket.ErrExpired) {
+			return nil, apperror.UnauthorizedError(err, "Access token has expired")
+		}
+		return nil, apperror.UnauthorizedError(err, "Invalid access token")
+	}
+
+	epoch, err := s.sessionRepo.Epoch(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Epoch < epoch {
+		return nil, apperror.UnauthorizedError(errors.New("access token of a revoked epoch"), "Session has been revoked")
+	}
+
+	session, err := s.sessionRepo.FindByID(claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.RevokedAt != nil {
+		return nil, apperror.UnauthorizedError(errors.New("access token of a revoked session"), "Session has been revoked")
+	}
+	return claims, nil
+}
+
+func (s *sessionUseCase) tokens(userID, sessionID, refreshToken string, refreshExpiresAt time.Time) (*domain.SessionTokens, error) {
+	epoch, err := s.sessionRepo.Epoch(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, accessExpiresAt, err := s.accessTokens.IssueForSession(userID, sessionID, epoch)
+	if err != nil {
+		return nil, apperror.InternalServerError(err, "failed to issue access token")
+	}
+	return &domain.SessionTokens{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+func newRefreshToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,226 @@
+package session_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/internal/domain"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/session"
+	"github.com/yokeTH/chat-app-backend/pkg/ticket"
+)
+
+// memoryRepository keeps sessions in a map, UpdatedAt can be moved back to
+// step over the reuse grace period.
+type memoryRepository struct {
+	sessions map[string]*domain.Session
+	epochs   map[string]int64
+}
+
+func (r *memoryRepository) Create(s *domain.Session) error {
+	s.ID = s.TokenHash
+	s.UpdatedAt = time.Now()
+	r.sessions[s.ID] = s
+	return nil
+}
+
+func (r *memoryRepository) FindByID(id string) (*domain.Session, error) {
+	s, ok := r.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *s
+	return &copied, nil
+}
+
+func (r *memoryRepository) FindByTokenHash(hash string) (*domain.Session, error) {
+	for _, s := range r.sessions {
+		if s.TokenHash == hash {
+			copied := *s
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRepository) FindByPreviousTokenHash(hash string) (*domain.Session, error) {
+	for _, s := range r.sessions {
+		if s.PreviousTokenHash == hash {
+			copied := *s
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRepository) Rotate(id, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	s, ok := r.sessions[id]
+	if !ok || s.TokenHash != oldHash || s.RevokedAt != nil {
+		return false, nil
+	}
+	s.PreviousTokenHash, s.TokenHash, s.ExpiresAt, s.UpdatedAt = oldHash, newHash, expiresAt, time.Now()
+	return true, nil
+}
+
+func (r *memoryRepository) Revoke(id string) error {
+	if s, ok := r.sessions[id]; ok && s.RevokedAt == nil {
+		now := time.Now()
+		s.RevokedAt = &now
+	}
+	return nil
+}
+
+func (r *memoryRepository) RevokeByUserID(userID string) error {
+	for id, s := range r.sessions {
+		if s.UserID == userID {
+			_ = r.Revoke(id)
+		}
+	}
+	r.epochs[userID]++
+	return nil
+}
+
+func (r *memoryRepository) Epoch(userID string) (int64, error) {
+	return r.epochs[userID], nil
+}
+
+func (r *memoryRepository) age(by time.Duration) {
+	for _, s := range r.sessions {
+		s.UpdatedAt = s.UpdatedAt.Add(-by)
+	}
+}
+
+func newUseCase(t *testing.T) (session.SessionUseCase, *memoryRepository) {
+	accessTokens, err := ticket.New(ticket.Config{Secret: "secret", TTL: time.Minute, Audience: ticket.AudienceAccess})
+	assert.Nil(t, err)
+	repo := &memoryRepository{sessions: make(map[string]*domain.Session), epochs: make(map[string]int64)}
+	return session.NewSessionUseCase(repo, accessTokens, session.Config{RefreshTTL: time.Hour}), repo
+}
+
+func TestSessionRefresh(t *testing.T) {
+	uc, _ := newUseCase(t)
+
+	tokens, err := uc.Start("user-1")
+	assert.Nil(t, err)
+
+	claims, err := uc.Authenticate(tokens.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.NotEmpty(t, claims.SessionID)
+
+	refreshed, err := uc.Refresh(tokens.RefreshToken)
+	assert.Nil(t, err)
+	assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+
+	refreshedClaims, err := uc.Authenticate(refreshed.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "user-1", refreshedClaims.UserID)
+	assert.Equal(t, claims.SessionID, refreshedClaims.SessionID)
+}
+
+func TestSessionRefreshReuse(t *testing.T) {
+	tests := []struct {
+		description string
+		age         time.Duration
+		revoked     bool
+	}{
+		{
+			description: "within the grace period",
+		},
+		{
+			description: "after the grace period",
+			age:         time.Minute,
+			revoked:     true,
+		},
+	}
+
+	for _, test := range tests {
+		uc, repo := newUseCase(t)
+
+		tokens, err := uc.Start("user-1")
+		assert.Nil(t, err)
+		refreshed, err := uc.Refresh(tokens.RefreshToken)
+		assert.Nil(t, err)
+		repo.age(test.age)
+
+		_, err = uc.Refresh(tokens.RefreshToken)
+		assert.NotNilf(t, err, test.description)
+
+		_, err = uc.Refresh(refreshed.RefreshToken)
+		if test.revoked {
+			assert.NotNilf(t, err, test.description)
+		} else {
+			assert.Nilf(t, err, test.description)
+		}
+	}
+}
+
+func TestSessionLogout(t *testing.T) {
+	uc, _ := newUseCase(t)
+
+	tokens, err := uc.Start("user-1")
+	assert.Nil(t, err)
+	other, err := uc.Start("user-1")
+	assert.Nil(t, err)
+	claims, err := uc.Authenticate(tokens.AccessToken)
+	assert.Nil(t, err)
+
+	sessionID, err := uc.Logout(tokens.RefreshToken)
+	assert.Nil(t, err)
+	assert.Equal(t, claims.SessionID, sessionID)
+
+	sessionID, err = uc.Logout(tokens.RefreshToken)
+	assert.Nil(t, err)
+	assert.Empty(t, sessionID)
+
+	_, err = uc.Refresh(tokens.RefreshToken)
+	assert.NotNil(t, err)
+	_, err = uc.Authenticate(tokens.AccessToken)
+	assert.NotNil(t, err, "the access token of the session is revoked with it")
+
+	// the other session of the user stays logged in
+	_, err = uc.Authenticate(other.AccessToken)
+	assert.Nil(t, err)
+}
+
+func TestSessionLogoutAll(t *testing.T) {
+	uc, _ := newUseCase(t)
+
+	tokens, err := uc.Start("user-1")
+	assert.Nil(t, err)
+	other, err := uc.Start("user-2")
+	assert.Nil(t, err)
+
+	assert.Nil(t, uc.LogoutAll("user-1"))
+
+	_, err = uc.Authenticate(tokens.AccessToken)
+	assert.NotNil(t, err)
+	_, err = uc.Refresh(tokens.RefreshToken)
+	assert.NotNil(t, err)
+
+	claims, err := uc.Authenticate(other.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "user-2", claims.UserID)
+
+	// a session started after LogoutAll is in the new epoch
+	tokens, err = uc.Start("user-1")
+	assert.Nil(t, err)
+	claims, err = uc.Authenticate(tokens.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+}
+
+func TestSessionAuthenticateInvalid(t *testing.T) {
+	uc, _ := newUseCase(t)
+
+	_, err := uc.Authenticate("not-a-token")
+	assert.NotNil(t, err)
+
+	// a websocket ticket signed with the same secret is not an access token
+	wsTickets, err := ticket.New(ticket.Config{Secret: "secret", TTL: time.Minute, Audience: ticket.AudienceWebSocket})
+	assert.Nil(t, err)
+	wsTicket, _, err := wsTickets.Issue("user-1")
+	assert.Nil(t, err)
+	_, err = uc.Authenticate(wsTicket)
+	assert.NotNil(t, err)
+}
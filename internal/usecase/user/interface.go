@@ -1,6 +1,8 @@
 package user
 
 import (
+	"time"
+
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
 	"github.com/yokeTH/chat-app-backend/internal/domain"
 )
@@ -8,17 +10,32 @@ import (
 type UserRepository interface {
 	GetUserByID(id string) (*domain.User, error)
 	GetUserByProvider(provider, providerID string) (*domain.User, error)
+	GetUserByEmail(email string) (*domain.User, error)
 	CreateUser(user *domain.User) (*domain.User, error)
+	LinkProvider(userID, provider, providerID string, dropPassword bool) error
 	UpdateUserInfo(userID string, updatedData dto.UpdateUserRequest) error
 	SetIsOnline(userID string, isOnline bool) error
+	SetPresence(userID string, presence domain.PresenceStatus) error
+	GetUsersByIDs(ids []string) (*[]domain.User, error)
+	RecordActivity(userID string) (bool, error)
+	MarkIdleAway(userIDs []string, idleSince time.Time) ([]string, error)
 	ListUser(page, limit int) (*[]domain.User, int, int, error)
 }
 
+type ContactRepository interface {
+	GetContactIDs(userID string) ([]string, error)
+}
+
 type UserUseCase interface {
 	GoogleLogin(profile domain.Profile) (*domain.User, error)
-	List(page, limit int) (*[]domain.User, int, int, error)
+	List(viewerID string, page, limit int) (*[]domain.User, int, int, error)
 	Update(id string, updatedData dto.UpdateUserRequest) (*domain.User, error)
 	SetUserOnline(id string) error
 	SetUserOffline(id string) error
+	SetPresence(id string, presence domain.PresenceStatus) (*domain.User, error)
+	GetPresence(viewerID string, ids []string) (*[]domain.User, error)
+	GetByID(id string) (*domain.User, error)
+	RecordActivity(id string) (bool, error)
+	MarkIdleAway(ids []string, idleFor time.Duration) ([]string, error)
 	GetGoogleProfile(googleID string) (*domain.User, error)
 }
@@ -1,33 +1,60 @@
 package user
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
 	"github.com/yokeTH/chat-app-backend/internal/domain"
 	"github.com/yokeTH/chat-app-backend/pkg/apperror"
 )
 
+const maxPresenceQuery = 100
+
 type userUseCase struct {
-	userRepo UserRepository
+	userRepo    UserRepository
+	contactRepo ContactRepository
 }
 
-func NewUserUseCase(userRepo UserRepository) *userUseCase {
+func NewUserUseCase(userRepo UserRepository, contactRepo ContactRepository) *userUseCase {
 	return &userUseCase{
-		userRepo: userRepo,
+		userRepo:    userRepo,
+		contactRepo: contactRepo,
 	}
 }
 
+// GoogleLogin signs in the user of a Google profile, creating them on their
+// first sign in. A local account with the same email becomes that user, the
+// profile comes from a verified Google email.
 func (u *userUseCase) GoogleLogin(profile domain.Profile) (*domain.User, error) {
-	user, err := u.userRepo.GetUserByProvider("GOOGLE", profile.Sub)
+	user, err := u.userRepo.GetUserByProvider(domain.ProviderGoogle, profile.Sub)
 	if err == nil {
 		return user, nil
 	}
+	if !hasCode(err, fiber.StatusNotFound) {
+		return nil, err
+	}
+
+	email := strings.ToLower(profile.Email)
+	existing, err := u.userRepo.GetUserByEmail(email)
+	switch {
+	case err == nil:
+		return u.linkGoogle(existing, profile)
+	case !hasCode(err, fiber.StatusNotFound):
+		return nil, err
+	}
 
+	now := time.Now()
 	newUser := domain.User{
-		Name:       profile.Name,
-		Email:      profile.Email,
-		AvatarURL:  profile.Picture,
-		Provider:   "GOOGLE",
-		ProviderID: profile.Sub,
+		Name:            profile.Name,
+		Email:           email,
+		AvatarURL:       profile.Picture,
+		Provider:        domain.ProviderGoogle,
+		ProviderID:      profile.Sub,
+		EmailVerifiedAt: &now,
 	}
 
 	createdUser, err := u.userRepo.CreateUser(&newUser)
@@ -38,8 +65,39 @@ func (u *userUseCase) GoogleLogin(profile domain.Profile) (*domain.User, error)
 	return createdUser, nil
 }
 
-func (u *userUseCase) List(page, limit int) (*[]domain.User, int, int, error) {
-	return u.userRepo.ListUser(page, limit)
+// linkGoogle moves a local account over to the Google profile with the same
+// email. The password of an account whose email was never verified is
+// dropped, whoever set it did not prove they own the email.
+func (u *userUseCase) linkGoogle(user *domain.User, profile domain.Profile) (*domain.User, error) {
+	if user.Provider == domain.ProviderGoogle {
+		return nil, apperror.ConflictError(fmt.Errorf("email of user %s belongs to another Google account", user.ID), "email is registered with another Google account")
+	}
+
+	if err := u.userRepo.LinkProvider(user.ID, domain.ProviderGoogle, profile.Sub, !user.EmailVerified()); err != nil {
+		return nil, err
+	}
+	return u.userRepo.GetUserByID(user.ID)
+}
+
+// List returns a page of all users. The presence of users that do not share
+// a conversation with viewerID is hidden, they are listed as offline.
+func (u *userUseCase) List(viewerID string, page, limit int) (*[]domain.User, int, int, error) {
+	users, last, total, err := u.userRepo.ListUser(page, limit)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	visible, err := u.visibleTo(viewerID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	for i := range *users {
+		if !visible[(*users)[i].ID] {
+			(*users)[i].IsOnline = false
+			(*users)[i].LastSeenAt = nil
+		}
+	}
+	return users, last, total, nil
 }
 
 func (u *userUseCase) Update(id string, updatedData dto.UpdateUserRequest) (*domain.User, error) {
@@ -61,6 +119,86 @@ func (u *userUseCase) SetUserOffline(id string) error {
 	return u.userRepo.SetIsOnline(id, false)
 }
 
+// SetPresence sets the state shown while the user is connected. Away is
+// usually entered and left automatically as the user goes idle and comes back.
+func (u *userUseCase) SetPresence(id string, presence domain.PresenceStatus) (*domain.User, error) {
+	switch presence {
+	case domain.PresenceOnline, domain.PresenceAway, domain.PresenceDoNotDisturb, domain.PresenceInvisible:
+	default:
+		return nil, apperror.BadRequestError(fmt.Errorf("invalid presence %q", presence), "presence must be one of online, away, dnd or invisible")
+	}
+
+	if err := u.userRepo.SetPresence(id, presence); err != nil {
+		return nil, err
+	}
+	return u.userRepo.GetUserByID(id)
+}
+
+// GetPresence returns the presence of the users among ids that viewerID may
+// see, which are the viewer and the users sharing a conversation with them.
+func (u *userUseCase) GetPresence(viewerID string, ids []string) (*[]domain.User, error) {
+	if len(ids) == 0 {
+		return nil, apperror.BadRequestError(errors.New("presence query without ids"), "at least one user id is required")
+	}
+	if len(ids) > maxPresenceQuery {
+		return nil, apperror.BadRequestError(fmt.Errorf("presence query with %d ids", len(ids)), fmt.Sprintf("at most %d user ids can be queried at once", maxPresenceQuery))
+	}
+
+	visible, err := u.visibleTo(viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if visible[id] {
+			allowed = append(allowed, id)
+		}
+	}
+	if len(allowed) == 0 {
+		return &[]domain.User{}, nil
+	}
+	return u.userRepo.GetUsersByIDs(allowed)
+}
+
+// visibleTo returns the users whose presence viewerID may see.
+func (u *userUseCase) visibleTo(viewerID string) (map[string]bool, error) {
+	contactIDs, err := u.contactRepo.GetContactIDs(viewerID)
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[string]bool, len(contactIDs)+1)
+	visible[viewerID] = true
+	for _, id := range contactIDs {
+		visible[id] = true
+	}
+	return visible, nil
+}
+
+// RecordActivity keeps the user from going idle, it reports whether the user
+// was away and is now back online.
+func (u *userUseCase) RecordActivity(id string) (bool, error) {
+	return u.userRepo.RecordActivity(id)
+}
+
+// MarkIdleAway moves users that have shown no activity for idleFor to away and
+// returns who was moved.
+func (u *userUseCase) MarkIdleAway(ids []string, idleFor time.Duration) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return u.userRepo.MarkIdleAway(ids, time.Now().Add(-idleFor))
+}
+
+func (u *userUseCase) GetByID(id string) (*domain.User, error) {
+	return u.userRepo.GetUserByID(id)
+}
+
 func (u *userUseCase) GetGoogleProfile(googleID string) (*domain.User, error) {
-	return u.userRepo.GetUserByProvider("GOOGLE", googleID)
+	return u.userRepo.GetUserByProvider(domain.ProviderGoogle, googleID)
+}
+
+func hasCode(err error, code int) bool {
+	appErr, ok := err.(*apperror.AppError)
+	return ok && appErr.Code == code
 }
@@ -9,19 +9,26 @@ import (
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/dto"
+	"github.com/yokeTH/chat-app-backend/internal/adaptor/google"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/handler"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/middleware"
 	"github.com/yokeTH/chat-app-backend/internal/adaptor/repository"
 	wsAdaptor "github.com/yokeTH/chat-app-backend/internal/adaptor/websocket"
 	"github.com/yokeTH/chat-app-backend/internal/config"
 	"github.com/yokeTH/chat-app-backend/internal/server"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/account"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/book"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/conversation"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/file"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/message"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/reaction"
+	"github.com/yokeTH/chat-app-backend/internal/usecase/session"
 	"github.com/yokeTH/chat-app-backend/internal/usecase/user"
+	"github.com/yokeTH/chat-app-backend/pkg/backplane"
 	"github.com/yokeTH/chat-app-backend/pkg/db"
+	"github.com/yokeTH/chat-app-backend/pkg/mailer"
 	"github.com/yokeTH/chat-app-backend/pkg/storage"
+	"github.com/yokeTH/chat-app-backend/pkg/ticket"
 )
 
 // @title GO-FIBER-TEMPLATE API
@@ -48,6 +55,49 @@ func main() {
 		log.Fatalf("failed to create public bucket instance: %v", err)
 	}
 
+	messageBackplane, err := backplane.New(config.Backplane)
+	if err != nil {
+		log.Fatalf("failed to create message backplane: %v", err)
+	}
+	defer messageBackplane.Close()
+
+	googleVerifier, err := google.New(config.Google)
+	if err != nil {
+		log.Fatalf("failed to create google token verifier: %v", err)
+	}
+
+	// a ticket may be redeemed on another replica than the one that issued it
+	config.Ticket.RequireSecret = config.Backplane.Driver != "" && config.Backplane.Driver != backplane.DriverMemory
+	config.Ticket.Audience = ticket.AudienceWebSocket
+	wsTicketer, err := ticket.New(config.Ticket)
+	if err != nil {
+		log.Fatalf("failed to create websocket ticketer: %v", err)
+	}
+	streamTicketConfig := config.Ticket
+	streamTicketConfig.Audience = ticket.AudienceEventStream
+	streamTicketer, err := ticket.New(streamTicketConfig)
+	if err != nil {
+		log.Fatalf("failed to create event stream ticketer: %v", err)
+	}
+
+	// outside development mails have to reach the user, and the links in them stay out of the logs
+	config.Mailer.RequireDelivery = config.Server.Env != "dev"
+	accountMailer, err := mailer.New(config.Mailer)
+	if err != nil {
+		log.Fatalf("failed to create mailer: %v", err)
+	}
+
+	// a random secret would log everyone out on restart and on every other replica
+	accessTokens, err := ticket.New(ticket.Config{
+		Secret:        config.Session.Secret,
+		TTL:           config.Session.AccessTTL,
+		Audience:      ticket.AudienceAccess,
+		RequireSecret: true,
+	})
+	if err != nil {
+		log.Fatalf("failed to create access token issuer: %v", err)
+	}
+
 	// Setup Translator (Dto)
 	fileDto := dto.NewFileDto(publicBucket)
 	userDto := dto.NewUserDto()
@@ -61,28 +111,44 @@ func main() {
 	userRepo := repository.NewUserRepository(db)
 	conversationRepo := repository.NewConversationRepository(db)
 	messageRepo := repository.NewMessageRepository(db)
+	reactionRepo := repository.NewReactionRepository(db)
+	eventRepo := repository.NewEventRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	passwordResetRepo := repository.NewPasswordResetRepository(db)
+	emailVerificationRepo := repository.NewEmailVerificationRepository(db)
 
 	// Setup use cases
 	bookUC := book.NewBookUseCase(bookRepo)
 	fileUC := file.NewFileUseCase(fileRepo, publicBucket)
-	msgUC := message.NewMessageUseCase(messageRepo)
-	userUC := user.NewUserUseCase(userRepo)
-	conversationUC := conversation.NewConversationUseCase(conversationRepo)
+	userUC := user.NewUserUseCase(userRepo, conversationRepo)
+	conversationUC := conversation.NewConversationUseCase(conversationRepo, eventRepo, messageRepo)
+	msgUC := message.NewMessageUseCase(messageRepo, conversationUC)
+	reactionUC := reaction.NewReactionUseCase(reactionRepo, messageRepo, conversationUC)
+	sessionUC := session.NewSessionUseCase(sessionRepo, accessTokens, config.Session)
+	accountUC := account.NewAccountUseCase(userRepo, passwordResetRepo, emailVerificationRepo, accountMailer, config.Password)
 
 	// Setup message server
-	msgServer := wsAdaptor.NewMessageServer(userUC, msgUC, conversationUC, messageDto)
-	go msgServer.Start(ctx, stop)
+	msgServer := wsAdaptor.NewMessageServer(config.WebSocket, userUC, msgUC, conversationUC, reactionUC, messageDto, reactionDto, conversationDto, messageBackplane, wsTicketer, streamTicketer)
+	msgServerDone := make(chan struct{})
+	go func() {
+		msgServer.Start(ctx, stop)
+		close(msgServerDone)
+	}()
 
 	// Setup handlers
-	authHandler := handler.NewAuthHandler(userUC)
+	authHandler := handler.NewAuthHandler(userUC, accountUC, sessionUC, wsTicketer, streamTicketer, userDto, msgServer)
 	bookHandler := handler.NewBookHandler(bookUC)
 	fileHandler := handler.NewFileHandler(fileUC, fileDto, msgUC, messageDto, msgServer)
-	msgHandler := handler.NewMessageHandler(msgUC, messageDto)
-	conversationHandler := handler.NewConversationHandler(conversationUC, conversationDto, msgServer, msgUC, messageDto)
+	msgHandler := handler.NewMessageHandler(msgUC, messageDto, msgServer)
+	conversationHandler := handler.NewConversationHandler(conversationUC, conversationDto, msgServer, messageDto)
 	userHandler := handler.NewUserHandler(userUC, userDto, msgServer)
+	reactionHandler := handler.NewReactionHandler(reactionUC, reactionDto, msgServer)
+	adminHandler := handler.NewAdminHandler(msgServer)
 
 	// Setup middleware
-	authMiddleware := middleware.NewAuthMiddleware(userUC)
+	authMiddleware := middleware.NewAuthMiddleware(userUC, sessionUC, googleVerifier)
+	adminMiddleware := middleware.NewAdminMiddleware()
+	authLimitMiddleware := middleware.NewRateLimitMiddleware(config.AuthLimit)
 	wsMiddleware := middleware.NewWebsocketMiddleware()
 
 	// Setup server
@@ -98,13 +164,26 @@ func main() {
 	{
 		ws := s.Group("/ws", wsMiddleware.RequiredUpgradeProtocol)
 		{
-			ws.Get("/", websocket.New(msgServer.HandleWebsocket))
+			ws.Get("/", websocket.New(msgServer.HandleWebsocket, websocket.Config{Subprotocols: wsAdaptor.Subprotocols}))
 		}
 	}
+	{
+		s.Get("/events", msgServer.HandleEvents)
+	}
 	{
 		auth := s.Group("/auth")
 		{
-			auth.Post("/google", authMiddleware.Auth, authHandler.HandleGoogleLogin)
+			auth.Post("/google", authMiddleware.GoogleAuth, authHandler.HandleGoogleLogin)
+			auth.Post("/register", authLimitMiddleware.Mail, authHandler.HandleRegister)
+			auth.Post("/verify-email", authLimitMiddleware.Login, authHandler.HandleVerifyEmail)
+			auth.Post("/verify-email/resend", authLimitMiddleware.Mail, authHandler.HandleResendVerification)
+			auth.Post("/login", authLimitMiddleware.Login, authHandler.HandleLogin)
+			auth.Put("/password", authMiddleware.Auth, authHandler.HandleChangePassword)
+			auth.Post("/password/forgot", authLimitMiddleware.Mail, authHandler.HandleForgotPassword)
+			auth.Post("/password/reset", authLimitMiddleware.Login, authHandler.HandleResetPassword)
+			auth.Post("/refresh", authHandler.HandleRefresh)
+			auth.Post("/logout", authHandler.HandleLogout)
+			auth.Post("/ws-ticket", authMiddleware.Auth, authHandler.HandleIssueWebsocketTicket)
 		}
 	}
 	{
@@ -122,6 +201,8 @@ func main() {
 		{
 			message.Post("/", msgHandler.HandleCreateMessage)
 			message.Get("/:id", msgHandler.HandleGetMessage)
+			message.Post("/:id/reactions", reactionHandler.HandleAddReaction)
+			message.Delete("/:id/reactions", reactionHandler.HandleRemoveReaction)
 		}
 	}
 	{
@@ -133,6 +214,7 @@ func main() {
 			conversation.Get("/:id", conversationHandler.HandleGetConversation)
 			conversation.Post("/:id/files", fileHandler.CreateFile)
 			conversation.Post("/:id/join", conversationHandler.HandleJoinConversation)
+			conversation.Post("/:id/read", conversationHandler.HandleReadConversation)
 		}
 	}
 	{
@@ -140,10 +222,26 @@ func main() {
 		{
 			user.Get("/", userHandler.HandleListUser)
 			user.Get("/me", userHandler.HandleGetMe)
+			user.Get("/presence", userHandler.HandleGetPresence)
+			user.Post("/me/activity", userHandler.HandleActivity)
 			user.Patch("/:id", userHandler.HandleUpdateUser)
 		}
 	}
 
+	{
+		admin := s.Group("/admin", authMiddleware.Auth, adminMiddleware.RequireAdmin)
+		{
+			admin.Get("/connections", adminHandler.HandleListConnections)
+			admin.Get("/connections/stats", adminHandler.HandleConnectionStats)
+			admin.Delete("/connections/:id", adminHandler.HandleDisconnectConnection)
+			admin.Delete("/users/:id/connections", adminHandler.HandleDisconnectUser)
+			admin.Post("/users/:id/notices", adminHandler.HandleSendNotice)
+		}
+	}
+
 	// Start the server
 	s.Start(ctx, stop)
+
+	// wait for the sockets to be closed and their users set offline
+	<-msgServerDone
 }
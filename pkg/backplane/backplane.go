@@ -0,0 +1,78 @@
+package backplane
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	DriverMemory = "memory"
+	DriverRedis  = "redis"
+)
+
+type Config struct {
+	Driver   string `env:"DRIVER" envDefault:"memory"`
+	Addr     string `env:"ADDR" envDefault:"localhost:6379"`
+	Password string `env:"PASSWORD"`
+	DB       int    `env:"DB" envDefault:"0"`
+	Channel  string `env:"CHANNEL" envDefault:"chat:events"`
+	// ReplicaID names this replica in the connection counts, such as the pod
+	// name. A random ID is used when it is empty.
+	ReplicaID string `env:"REPLICA_ID"`
+	// HeartbeatInterval is how often a replica reports that it is alive, its
+	// connections are dropped after three intervals without a report.
+	HeartbeatInterval time.Duration `env:"HEARTBEAT_INTERVAL" envDefault:"10s"`
+}
+
+// Message is a frame that has to reach every replica. Each replica delivers
+// Payload to the sockets it holds for UserIDs, or to all of its sockets when
+// UserIDs is empty. When Topic is set, sockets that subscribed to other
+// topics only skip the message.
+type Message struct {
+	UserIDs []string `json:"user_ids,omitempty"`
+	Topic   string   `json:"topic,omitempty"`
+	// Kind optionally tags the message, so replicas can pick out the messages
+	// they keep state from without decoding every Payload.
+	Kind    string `json:"kind,omitempty"`
+	Payload []byte `json:"payload"`
+}
+
+type Handler func(msg Message)
+
+// Backplane fans frames out to every replica of the message server and keeps
+// a cluster wide count of live connections per user, so a user is only
+// reported offline once their last socket on any replica is gone. Counts are
+// kept per replica, so the connections of a replica that died without
+// closing them can be reaped. It also lets replicas agree on one-time claims,
+// such as single-use tickets.
+type Backplane interface {
+	Publish(ctx context.Context, msg Message) error
+	// Subscribe delivers every published message to handler until ctx is done.
+	Subscribe(ctx context.Context, handler Handler) error
+	IncrConnections(ctx context.Context, userID string) (int64, error)
+	DecrConnections(ctx context.Context, userID string) (int64, error)
+	// ReapConnections drops the connections counted for replicas that stopped
+	// sending heartbeats, and returns the users left without any connection.
+	ReapConnections(ctx context.Context) ([]string, error)
+	// Claim reports whether key is claimed for the first time on any replica,
+	// the claim is forgotten after ttl.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	Close() error
+}
+
+// New creates the backplane selected by config.Driver.
+//
+// Usage Example:
+//
+//	bp, err := backplane.New(backplane.Config{Driver: backplane.DriverRedis, Addr: "localhost:6379"})
+func New(config Config) (Backplane, error) {
+	switch config.Driver {
+	case "", DriverMemory:
+		return NewMemory(), nil
+	case DriverRedis:
+		return NewRedis(config)
+	default:
+		return nil, fmt.Errorf("unknown backplane driver: %s", config.Driver)
+	}
+}
@@ -0,0 +1,140 @@
+package backplane_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/pkg/backplane"
+)
+
+func receive(t *testing.T, ch <-chan backplane.Message) backplane.Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for backplane message")
+		return backplane.Message{}
+	}
+}
+
+func TestBackplane(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	tests := []struct {
+		description string
+		newReplica  func(t *testing.T) backplane.Backplane
+		shared      bool
+	}{
+		{
+			description: "memory",
+			shared:      true,
+		},
+		{
+			description: "redis",
+			newReplica: func(t *testing.T) backplane.Backplane {
+				bp, err := backplane.New(backplane.Config{Driver: backplane.DriverRedis, Addr: mr.Addr(), Channel: "test:events"})
+				assert.Nil(t, err)
+				return bp
+			},
+		},
+	}
+
+	for _, test := range tests {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// memory is single node, both "replicas" share one instance
+		var a, b backplane.Backplane
+		if test.shared {
+			a = backplane.NewMemory()
+			b = a
+		} else {
+			a = test.newReplica(t)
+			b = test.newReplica(t)
+		}
+
+		gotA := make(chan backplane.Message, 1)
+		gotB := make(chan backplane.Message, 1)
+		assert.Nilf(t, a.Subscribe(ctx, func(msg backplane.Message) { gotA <- msg }), test.description)
+		assert.Nilf(t, b.Subscribe(ctx, func(msg backplane.Message) { gotB <- msg }), test.description)
+
+		sent := backplane.Message{UserIDs: []string{"user-1"}, Topic: "conversation-1", Kind: "typing", Payload: []byte(`{"event":"typing_start"}`)}
+		assert.Nilf(t, a.Publish(ctx, sent), test.description)
+		assert.Equalf(t, sent, receive(t, gotA), test.description)
+		assert.Equalf(t, sent, receive(t, gotB), test.description)
+
+		count, err := a.IncrConnections(ctx, "user-1")
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, int64(1), count, test.description)
+		count, err = b.IncrConnections(ctx, "user-1")
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, int64(2), count, test.description)
+		count, err = a.DecrConnections(ctx, "user-1")
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, int64(1), count, test.description)
+		count, err = b.DecrConnections(ctx, "user-1")
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, int64(0), count, test.description)
+
+		claimed, err := a.Claim(ctx, "ticket-1", time.Minute)
+		assert.Nilf(t, err, test.description)
+		assert.Truef(t, claimed, test.description)
+		claimed, err = b.Claim(ctx, "ticket-1", time.Minute)
+		assert.Nilf(t, err, test.description)
+		assert.Falsef(t, claimed, test.description)
+
+		cancel()
+		assert.Nilf(t, a.Close(), test.description)
+		if !test.shared {
+			assert.Nilf(t, b.Close(), test.description)
+		}
+	}
+}
+
+func TestRedisReapConnections(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	newReplica := func(id string) backplane.Backplane {
+		bp, err := backplane.New(backplane.Config{Driver: backplane.DriverRedis, Addr: mr.Addr(), Channel: "test:events", ReplicaID: id})
+		assert.Nil(t, err)
+		return bp
+	}
+	a := newReplica("a")
+	b := newReplica("b")
+	defer b.Close()
+
+	_, err := a.IncrConnections(ctx, "user-1")
+	assert.Nil(t, err)
+	_, err = a.IncrConnections(ctx, "user-2")
+	assert.Nil(t, err)
+	count, err := b.IncrConnections(ctx, "user-2")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// a live replica is left alone
+	offline, err := b.ReapConnections(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, offline)
+
+	// a crashed replica stops refreshing its heartbeat
+	mr.Del("chat:replica:a")
+
+	offline, err = b.ReapConnections(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"user-1"}, offline)
+
+	count, err = b.DecrConnections(ctx, "user-2")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+
+	// a decrement without a matching increment does not go below zero
+	count, err = b.DecrConnections(ctx, "user-1")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+
+	assert.Nil(t, a.Close())
+}
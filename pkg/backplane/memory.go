@@ -0,0 +1,101 @@
+package backplane
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryBackplane struct {
+	mu          sync.RWMutex
+	handlers    map[int]Handler
+	nextID      int
+	connections map[string]int64
+	claims      map[string]time.Time
+}
+
+// NewMemory creates a backplane for a single replica, published messages are
+// handed straight to the local subscribers.
+func NewMemory() *memoryBackplane {
+	return &memoryBackplane{
+		handlers:    make(map[int]Handler),
+		connections: make(map[string]int64),
+		claims:      make(map[string]time.Time),
+	}
+}
+
+func (b *memoryBackplane) Publish(ctx context.Context, msg Message) error {
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+func (b *memoryBackplane) Subscribe(ctx context.Context, handler Handler) error {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}()
+	return nil
+}
+
+func (b *memoryBackplane) IncrConnections(ctx context.Context, userID string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.connections[userID]++
+	return b.connections[userID], nil
+}
+
+func (b *memoryBackplane) DecrConnections(ctx context.Context, userID string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.connections[userID]--
+	count := b.connections[userID]
+	if count <= 0 {
+		delete(b.connections, userID)
+		count = 0
+	}
+	return count, nil
+}
+
+// ReapConnections has nothing to do, the only replica is this one.
+func (b *memoryBackplane) ReapConnections(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (b *memoryBackplane) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for claimed, expiresAt := range b.claims {
+		if now.After(expiresAt) {
+			delete(b.claims, claimed)
+		}
+	}
+
+	if _, ok := b.claims[key]; ok {
+		return false, nil
+	}
+	b.claims[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (b *memoryBackplane) Close() error {
+	return nil
+}
@@ -0,0 +1,250 @@
+package backplane
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// connectionsKeyPrefix holds a hash per user, of connection counts by replica
+	connectionsKeyPrefix = "chat:connections:"
+	// replicaUsersKeyPrefix holds a set per replica, of the users it counts
+	// connections for, so its counts can be found once it is gone
+	replicaUsersKeyPrefix = "chat:replica-users:"
+	// replicaKeyPrefix holds the heartbeat of a replica, it expires when the
+	// replica stops refreshing it
+	replicaKeyPrefix = "chat:replica:"
+	replicasKey      = "chat:replicas"
+	claimsKeyPrefix  = "chat:claims:"
+)
+
+const defaultHeartbeatInterval = 10 * time.Second
+
+// countConnections adds ARGV[2] to the connections of user ARGV[3] on replica
+// ARGV[1] and returns the user's connections on every replica. A replica's
+// count is removed instead of going below one.
+var countConnections = redis.NewScript(`
+local count = redis.call('HINCRBY', KEYS[1], ARGV[1], ARGV[2])
+if count <= 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+	redis.call('SREM', KEYS[2], ARGV[3])
+else
+	redis.call('SADD', KEYS[2], ARGV[3])
+end
+local total = 0
+for _, value in ipairs(redis.call('HVALS', KEYS[1])) do
+	total = total + tonumber(value)
+end
+return total
+`)
+
+// reapConnections removes the connections of replica ARGV[1] from a user and
+// returns the user's connections on the other replicas.
+var reapConnections = redis.NewScript(`
+redis.call('HDEL', KEYS[1], ARGV[1])
+local total = 0
+for _, value in ipairs(redis.call('HVALS', KEYS[1])) do
+	total = total + tonumber(value)
+end
+return total
+`)
+
+type redisBackplane struct {
+	client    *redis.Client
+	channel   string
+	replicaID string
+	heartbeat time.Duration
+	stop      context.CancelFunc
+	stopped   chan struct{}
+}
+
+// NewRedis creates a backplane that fans messages out through Redis pub/sub.
+func NewRedis(config Config) (*redisBackplane, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
+	})
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+
+	replicaID := config.ReplicaID
+	if replicaID == "" {
+		replicaID = uuid.New().String()
+	}
+	heartbeat := config.HeartbeatInterval
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeatInterval
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	b := &redisBackplane{
+		client:    client,
+		channel:   config.Channel,
+		replicaID: replicaID,
+		heartbeat: heartbeat,
+		stop:      stop,
+		stopped:   make(chan struct{}),
+	}
+
+	// the replica must be alive before it counts any connection, or another
+	// replica could reap them right away
+	if err := b.beat(ctx); err != nil {
+		stop()
+		client.Close()
+		return nil, err
+	}
+	go b.heartbeatProcess(ctx)
+
+	return b, nil
+}
+
+func (b *redisBackplane) beat(ctx context.Context) error {
+	pipe := b.client.TxPipeline()
+	pipe.Set(ctx, replicaKeyPrefix+b.replicaID, time.Now().Unix(), 3*b.heartbeat)
+	pipe.SAdd(ctx, replicasKey, b.replicaID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *redisBackplane) heartbeatProcess(ctx context.Context) {
+	defer close(b.stopped)
+
+	ticker := time.NewTicker(b.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.beat(ctx); err != nil {
+				log.Printf("failed to send backplane heartbeat: %v", err)
+			}
+		}
+	}
+}
+
+func (b *redisBackplane) Publish(ctx context.Context, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+func (b *redisBackplane) Subscribe(ctx context.Context, handler Handler) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+
+	// wait for the subscription to be confirmed so nothing published after
+	// Subscribe returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	go func() {
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-ch:
+				if !ok {
+					return
+				}
+				var msg Message
+				if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+					log.Printf("invalid backplane message: %v", err)
+					continue
+				}
+				handler(msg)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (b *redisBackplane) IncrConnections(ctx context.Context, userID string) (int64, error) {
+	return b.countConnections(ctx, userID, 1)
+}
+
+func (b *redisBackplane) DecrConnections(ctx context.Context, userID string) (int64, error) {
+	return b.countConnections(ctx, userID, -1)
+}
+
+func (b *redisBackplane) countConnections(ctx context.Context, userID string, delta int) (int64, error) {
+	return countConnections.Run(ctx, b.client,
+		[]string{connectionsKeyPrefix + userID, replicaUsersKeyPrefix + b.replicaID},
+		b.replicaID, delta, userID,
+	).Int64()
+}
+
+func (b *redisBackplane) ReapConnections(ctx context.Context) ([]string, error) {
+	replicaIDs, err := b.client.SMembers(ctx, replicasKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var offline []string
+	for _, replicaID := range replicaIDs {
+		if replicaID == b.replicaID {
+			continue
+		}
+		alive, err := b.client.Exists(ctx, replicaKeyPrefix+replicaID).Result()
+		if err != nil {
+			return offline, err
+		}
+		if alive > 0 {
+			continue
+		}
+
+		userIDs, err := b.client.SMembers(ctx, replicaUsersKeyPrefix+replicaID).Result()
+		if err != nil {
+			return offline, err
+		}
+		log.Printf("replica %s stopped sending heartbeats, dropping the connections of %d users", replicaID, len(userIDs))
+
+		for _, userID := range userIDs {
+			remaining, err := reapConnections.Run(ctx, b.client, []string{connectionsKeyPrefix + userID}, replicaID).Int64()
+			if err != nil {
+				return offline, err
+			}
+			if remaining <= 0 {
+				offline = append(offline, userID)
+			}
+		}
+
+		if err := b.client.Del(ctx, replicaUsersKeyPrefix+replicaID).Err(); err != nil {
+			return offline, err
+		}
+		if err := b.client.SRem(ctx, replicasKey, replicaID).Err(); err != nil {
+			return offline, err
+		}
+	}
+	return offline, nil
+}
+
+func (b *redisBackplane) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return b.client.SetNX(ctx, claimsKeyPrefix+key, 1, ttl).Result()
+}
+
+// Close stops the heartbeat. Connections still counted for this replica are
+// reaped by the other replicas once its heartbeat is gone.
+func (b *redisBackplane) Close() error {
+	b.stop()
+	<-b.stopped
+	if err := b.client.Del(context.Background(), replicaKeyPrefix+b.replicaID).Err(); err != nil {
+		log.Printf("failed to remove backplane heartbeat: %v", err)
+	}
+	return b.client.Close()
+}
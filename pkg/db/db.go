@@ -1,6 +1,7 @@
 package db
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -56,3 +57,13 @@ func Paginate(model any, limit, page, total, last *int) func(db *gorm.DB) *gorm.
 		return db.Offset(offset).Limit(*limit)
 	}
 }
+
+// IsDuplicatedKey reports whether err is a unique constraint violation. The
+// error is translated here rather than through gorm.Config.TranslateError, so
+// repositories that do not ask keep seeing the driver's errors.
+func IsDuplicatedKey(tx *gorm.DB, err error) bool {
+	if translator, ok := tx.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
@@ -0,0 +1,25 @@
+package mailer
+
+import (
+	"context"
+	"log"
+)
+
+type logMailer struct {
+	from string
+}
+
+// NewLog creates a mailer that writes every mail to the log instead of
+// sending it, links in the mail can be copied from there. The links grant
+// access to accounts, it is for development only.
+func NewLog(from string) *logMailer {
+	return &logMailer{from: from}
+}
+
+func (m *logMailer) Send(ctx context.Context, mail Mail) error {
+	if mail.From == "" {
+		mail.From = m.from
+	}
+	log.Printf("mail from %s to %s: %s\n%s", mail.From, mail.To, mail.Subject, mail.Body)
+	return nil
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	DriverLog    = "log"
+	DriverMemory = "memory"
+	DriverSMTP   = "smtp"
+)
+
+var ErrUndelivered = errors.New("mailer driver does not deliver mail")
+
+type Config struct {
+	Driver string `env:"DRIVER" envDefault:"log"`
+	From   string `env:"FROM" envDefault:"no-reply@localhost"`
+	// SMTPAddr is the host:port of the server the smtp driver relays through,
+	// SMTPUsername is left empty for a server without authentication.
+	SMTPAddr     string `env:"SMTP_ADDR"`
+	SMTPUsername string `env:"SMTP_USERNAME"`
+	SMTPPassword string `env:"SMTP_PASSWORD"`
+	// RequireDelivery makes New refuse the log and memory drivers. Mails hold
+	// password reset and verification links, the log driver writes them to
+	// the log and neither reaches the user.
+	RequireDelivery bool
+}
+
+type Mail struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers mails. Implementations for a real provider only need Send,
+// the log and memory mailers are meant for development and tests.
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// New creates the mailer selected by config.Driver.
+//
+// Usage Example:
+//
+//	m, err := mailer.New(mailer.Config{Driver: mailer.DriverLog})
+//	err = m.Send(ctx, mailer.Mail{To: "user@example.com", Subject: "Hello", Body: "Hello!"})
+func New(config Config) (Mailer, error) {
+	switch config.Driver {
+	case "", DriverLog, DriverMemory:
+		if config.RequireDelivery {
+			return nil, fmt.Errorf("%w: %s, use %s", ErrUndelivered, config.Driver, DriverSMTP)
+		}
+		if config.Driver == DriverMemory {
+			return NewMemory(config.From), nil
+		}
+		return NewLog(config.From), nil
+	case DriverSMTP:
+		if config.SMTPAddr == "" {
+			return nil, errors.New("smtp mailer requires an address")
+		}
+		return NewSMTP(config.SMTPAddr, config.From, config.SMTPUsername, config.SMTPPassword), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer driver: %s", config.Driver)
+	}
+}
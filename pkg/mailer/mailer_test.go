@@ -0,0 +1,64 @@
+package mailer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/pkg/mailer"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		description   string
+		config        mailer.Config
+		expectedError error
+		failure       bool
+	}{
+		{
+			description: "log driver in development",
+			config:      mailer.Config{Driver: mailer.DriverLog},
+		},
+		{
+			description:   "log driver when delivery is required",
+			config:        mailer.Config{Driver: mailer.DriverLog, RequireDelivery: true},
+			expectedError: mailer.ErrUndelivered,
+		},
+		{
+			description:   "default driver when delivery is required",
+			config:        mailer.Config{RequireDelivery: true},
+			expectedError: mailer.ErrUndelivered,
+		},
+		{
+			description:   "memory driver when delivery is required",
+			config:        mailer.Config{Driver: mailer.DriverMemory, RequireDelivery: true},
+			expectedError: mailer.ErrUndelivered,
+		},
+		{
+			description: "smtp driver when delivery is required",
+			config:      mailer.Config{Driver: mailer.DriverSMTP, SMTPAddr: "localhost:25", RequireDelivery: true},
+		},
+		{
+			description: "smtp driver without an address",
+			config:      mailer.Config{Driver: mailer.DriverSMTP},
+			failure:     true,
+		},
+		{
+			description: "unknown driver",
+			config:      mailer.Config{Driver: "pigeon"},
+			failure:     true,
+		},
+	}
+
+	for _, test := range tests {
+		m, err := mailer.New(test.config)
+		switch {
+		case test.expectedError != nil:
+			assert.ErrorIsf(t, err, test.expectedError, test.description)
+		case test.failure:
+			assert.NotNilf(t, err, test.description)
+		default:
+			assert.Nilf(t, err, test.description)
+			assert.NotNilf(t, m, test.description)
+		}
+	}
+}
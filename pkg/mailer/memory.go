@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+)
+
+type memoryMailer struct {
+	from string
+
+	mu   sync.Mutex
+	sent []Mail
+}
+
+// NewMemory creates a mailer that keeps every mail, see Sent.
+func NewMemory(from string) *memoryMailer {
+	return &memoryMailer{from: from}
+}
+
+func (m *memoryMailer) Send(ctx context.Context, mail Mail) error {
+	if mail.From == "" {
+		mail.From = m.from
+	}
+	m.mu.Lock()
+	m.sent = append(m.sent, mail)
+	m.mu.Unlock()
+	return nil
+}
+
+// Sent returns the mails sent so far, oldest first.
+func (m *memoryMailer) Sent() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mail(nil), m.sent...)
+}
@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP creates a mailer that relays through the SMTP server at addr. The
+// connection is upgraded with STARTTLS when the server offers it, and
+// credentials are only sent over TLS or to localhost.
+func NewSMTP(addr, from, username, password string) *smtpMailer {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &smtpMailer{addr: addr, from: from, auth: auth}
+}
+
+// Send does not stop when ctx is done, net/smtp takes no context.
+func (m *smtpMailer) Send(ctx context.Context, mail Mail) error {
+	if mail.From == "" {
+		mail.From = m.from
+	}
+	if strings.ContainsAny(mail.From+mail.To, "\r\n") {
+		return errors.New("mail address contains a line break")
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", mail.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", mail.Subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+
+	if err := smtp.SendMail(m.addr, m.auth, mail.From, []string{mail.To}, msg.Bytes()); err != nil {
+		return fmt.Errorf("sending mail: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package ticket
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+var (
+	ErrMissingSecret   = errors.New("ticket secret is required")
+	ErrMissingAudience = errors.New("ticket audience is required")
+	ErrMalformed       = errors.New("malformed ticket")
+	ErrSignature       = errors.New("invalid ticket signature")
+	ErrAudience        = errors.New("ticket issued for another audience")
+	ErrExpired         = errors.New("ticket expired")
+)
+
+// The audiences of the tickets this service issues. Each ticketer only
+// accepts its own, so a ticket cannot stand in for another kind even when
+// both are signed with the same secret.
+const (
+	AudienceAccess      = "access"
+	AudienceWebSocket   = "websocket"
+	AudienceEventStream = "events"
+)
+
+type Config struct {
+	// Secret signs the tickets, every replica must share it. A random secret
+	// is generated when it is empty, which only works with a single replica.
+	Secret string        `env:"SECRET"`
+	TTL    time.Duration `env:"TTL" envDefault:"30s"`
+	// RequireSecret makes New fail on an empty Secret instead of generating
+	// one, for tickets that another replica may have to verify.
+	RequireSecret bool
+	// Audience is written into every ticket and checked by Verify.
+	Audience string
+}
+
+// Claims is what a ticket vouches for. ID is unique per ticket, so a ticket
+// can be redeemed once by claiming its ID. SessionID names the login session
+// the ticket was issued in, and Epoch lets the issuer revoke every ticket of
+// a user issued before it moved on to a newer epoch.
+type Claims struct {
+	UserID    string `json:"uid"`
+	SessionID string `json:"sid,omitempty"`
+	Audience  string `json:"aud"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+	Epoch     int64  `json:"epc,omitempty"`
+}
+
+func (c Claims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// Ticketer issues short-lived signed tickets and verifies them without any
+// outbound call. Verify does not enforce single use, see Claims.ID.
+type Ticketer interface {
+	Issue(userID string) (string, time.Time, error)
+	IssueForSession(userID, sessionID string, epoch int64) (string, time.Time, error)
+	Verify(token string) (*Claims, error)
+	TTL() time.Duration
+}
+
+type ticketer struct {
+	secret   []byte
+	ttl      time.Duration
+	audience string
+}
+
+// New creates a ticketer signing with config.Secret.
+//
+// Usage Example:
+//
+//	t, err := ticket.New(ticket.Config{Secret: "change-me", TTL: 30 * time.Second, Audience: ticket.AudienceWebSocket})
+//	token, expiresAt, err := t.Issue(userID)
+func New(config Config) (*ticketer, error) {
+	if config.Audience == "" {
+		return nil, ErrMissingAudience
+	}
+
+	secret := []byte(config.Secret)
+	if len(secret) == 0 {
+		if config.RequireSecret {
+			return nil, ErrMissingSecret
+		}
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		log.Printf("ticket secret is not set, using a random secret that other replicas do not share")
+	}
+
+	ttl := config.TTL
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+
+	return &ticketer{secret: secret, ttl: ttl, audience: config.Audience}, nil
+}
+
+func (t *ticketer) TTL() time.Duration {
+	return t.ttl
+}
+
+func (t *ticketer) Issue(userID string) (string, time.Time, error) {
+	return t.IssueForSession(userID, "", 0)
+}
+
+func (t *ticketer) IssueForSession(userID, sessionID string, epoch int64) (string, time.Time, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(t.ttl).Truncate(time.Second)
+	payload, err := json.Marshal(Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Audience:  t.audience,
+		ExpiresAt: expiresAt.Unix(),
+		ID:        hex.EncodeToString(id),
+		Epoch:     epoch,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + t.sign(encoded), expiresAt, nil
+}
+
+func (t *ticketer) Verify(token string) (*Claims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrMalformed
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(t.sign(encoded))) {
+		return nil, ErrSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if claims.UserID == "" || claims.ID == "" {
+		return nil, ErrMalformed
+	}
+	if claims.Audience != t.audience {
+		return nil, ErrAudience
+	}
+
+	if !time.Now().Before(claims.Expiry()) {
+		return nil, ErrExpired
+	}
+
+	return &claims, nil
+}
+
+func (t *ticketer) sign(encoded string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
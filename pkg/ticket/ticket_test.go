@@ -0,0 +1,101 @@
+package ticket_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yokeTH/chat-app-backend/pkg/ticket"
+)
+
+func TestTicketVerify(t *testing.T) {
+	issuer, err := ticket.New(ticket.Config{Secret: "secret", TTL: time.Minute, Audience: ticket.AudienceWebSocket})
+	assert.Nil(t, err)
+	other, err := ticket.New(ticket.Config{Secret: "other", TTL: time.Minute, Audience: ticket.AudienceWebSocket})
+	assert.Nil(t, err)
+
+	valid, _, err := issuer.Issue("user-1")
+	assert.Nil(t, err)
+	foreign, _, err := other.Issue("user-1")
+	assert.Nil(t, err)
+	shortLived, err := ticket.New(ticket.Config{Secret: "secret", TTL: time.Nanosecond, Audience: ticket.AudienceWebSocket})
+	assert.Nil(t, err)
+	expired, _, err := shortLived.Issue("user-1")
+	assert.Nil(t, err)
+	accessTokens, err := ticket.New(ticket.Config{Secret: "secret", TTL: time.Minute, Audience: ticket.AudienceAccess})
+	assert.Nil(t, err)
+	accessToken, _, err := accessTokens.Issue("user-1")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		description   string
+		token         string
+		expectedError error
+	}{
+		{
+			description: "valid ticket",
+			token:       valid,
+		},
+		{
+			description:   "signed with another secret",
+			token:         foreign,
+			expectedError: ticket.ErrSignature,
+		},
+		{
+			description:   "tampered payload",
+			token:         "x" + valid,
+			expectedError: ticket.ErrSignature,
+		},
+		{
+			description:   "issued for another audience",
+			token:         accessToken,
+			expectedError: ticket.ErrAudience,
+		},
+		{
+			description:   "expired ticket",
+			token:         expired,
+			expectedError: ticket.ErrExpired,
+		},
+		{
+			description:   "missing signature",
+			token:         "payload",
+			expectedError: ticket.ErrMalformed,
+		},
+	}
+
+	for _, test := range tests {
+		claims, err := issuer.Verify(test.token)
+		if test.expectedError != nil {
+			assert.ErrorIsf(t, err, test.expectedError, test.description)
+			continue
+		}
+		assert.Nilf(t, err, test.description)
+		assert.Equalf(t, "user-1", claims.UserID, test.description)
+		assert.NotEmptyf(t, claims.ID, test.description)
+	}
+}
+
+func TestTicketNew(t *testing.T) {
+	_, err := ticket.New(ticket.Config{RequireSecret: true, Audience: ticket.AudienceAccess})
+	assert.ErrorIs(t, err, ticket.ErrMissingSecret)
+
+	_, err = ticket.New(ticket.Config{Audience: ticket.AudienceAccess})
+	assert.Nil(t, err)
+
+	_, err = ticket.New(ticket.Config{Secret: "secret"})
+	assert.ErrorIs(t, err, ticket.ErrMissingAudience)
+}
+
+func TestTicketSession(t *testing.T) {
+	issuer, err := ticket.New(ticket.Config{Secret: "secret", TTL: time.Minute, Audience: ticket.AudienceAccess})
+	assert.Nil(t, err)
+
+	token, _, err := issuer.IssueForSession("user-1", "session-1", 3)
+	assert.Nil(t, err)
+
+	claims, err := issuer.Verify(token)
+	assert.Nil(t, err)
+	assert.Equal(t, "session-1", claims.SessionID)
+	assert.Equal(t, int64(3), claims.Epoch)
+	assert.Equal(t, ticket.AudienceAccess, claims.Audience)
+}